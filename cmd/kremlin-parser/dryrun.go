@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/terratensor/kremlin-parser/internal/config"
+	"github.com/terratensor/kremlin-parser/internal/storage/memory"
+)
+
+// disableSideEffects отключает запись на диск всего, кроме журнала:
+// файлов записей, вложений, веб-архива, архива ответов и валидаторов страниц.
+// Без валидаторов страницы ленты загружаются заново и разбираются целиком.
+func disableSideEffects(cfg *config.Config) {
+	cfg.SaveToFile = false
+	cfg.DownloadAttachments = false
+	cfg.ConditionalGet = false
+	cfg.WARC.Enabled = false
+	cfg.HTTP.Record = false
+}
+
+// printDryRunSummary выводит записи, которые были бы добавлены или изменены,
+// и их количество по языкам. Запись, сохраненная несколько раз, выводится
+// один раз с первой операцией: новая запись остается новой после изменений.
+func printDryRunSummary(w io.Writer, changes []memory.Change) {
+	type item struct {
+		op, lang, url, title string
+	}
+
+	var items []item
+	seen := make(map[string]int)
+	for _, c := range changes {
+		if i, ok := seen[c.Entry.Url]; ok {
+			items[i].title = c.Entry.Title
+			continue
+		}
+		seen[c.Entry.Url] = len(items)
+		items = append(items, item{c.Op, c.Entry.Language, c.Entry.Url, c.Entry.Title})
+	}
+
+	counts := make(map[string]map[string]int)
+	for _, it := range items {
+		if counts[it.lang] == nil {
+			counts[it.lang] = make(map[string]int)
+		}
+		counts[it.lang][it.op]++
+		fmt.Fprintf(w, "%-6s %-2s %s %s\n", it.op, it.lang, it.url, it.title)
+	}
+
+	langs := make([]string, 0, len(counts))
+	for lang := range counts {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	fmt.Fprintln(w, "dry run summary:")
+	for _, lang := range langs {
+		fmt.Fprintf(w, "  %s: %d to insert, %d to update\n",
+			lang, counts[lang][memory.OpInsert], counts[lang][memory.OpUpdate])
+	}
+	fmt.Fprintf(w, "  total: %d entries\n", len(items))
+}
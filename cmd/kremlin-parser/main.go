@@ -3,13 +3,15 @@ package main
 import (
 	"context"
 	flag "github.com/spf13/pflag"
+	"github.com/terratensor/kremlin-parser/internal/checkpoint"
 	"github.com/terratensor/kremlin-parser/internal/config"
 	"github.com/terratensor/kremlin-parser/internal/crawler"
 	"github.com/terratensor/kremlin-parser/internal/entities/feed"
 	"github.com/terratensor/kremlin-parser/internal/lib/logger/handlers/slogpretty"
 	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
 	"github.com/terratensor/kremlin-parser/internal/parser"
-	"github.com/terratensor/kremlin-parser/internal/storage/manticore"
+	"github.com/terratensor/kremlin-parser/internal/storage/factory"
+	"github.com/terratensor/kremlin-parser/internal/storage/memory"
 	"log"
 	"log/slog"
 	"os"
@@ -36,21 +38,69 @@ func main() {
 
 	var demon bool
 	var pageCount int
+	var resume bool
+	var dryRun bool
+	var since, until string
+	var archivedAt string
 
 	flag.BoolVarP(&demon, "service", "s", false, "запуск парсера в режиме службы")
 	flag.IntVarP(&pageCount, "page-count", "p", 0, "спарсить указанное количество страниц")
+	flag.BoolVarP(&resume, "resume", "r", false, "продолжить обход ленты с последней контрольной точки")
+	flag.BoolVar(&dryRun, "dry-run", false, "спарсить ленты без сохранения и вывести, какие записи были бы добавлены или изменены")
+	flag.StringVar(&since, "since", "", "парсить записи, опубликованные начиная с даты (2006-01-02)")
+	flag.StringVar(&until, "until", "", "парсить записи, опубликованные по дату включительно (2006-01-02)")
+	flag.StringVar(&archivedAt, "archived-at", "", "reparse: разбирать страницы в том виде, в котором они получены не позже даты (2006-01-02 или RFC 3339)")
 	flag.Parse()
 
-	var storage feed.StorageInterface
+	if since != "" {
+		cfg.Since = since
+	}
+	if until != "" {
+		cfg.Until = until
+	}
+	if _, _, err := cfg.Parser.Period(); err != nil {
+		logger.Error("invalid publication period", sl.Err(err))
+		os.Exit(1)
+	}
 
-	manticoreClient, err := manticore.New(cfg.ManticoreIndex)
+	storage, err := factory.New(ctx, cfg)
 	if err != nil {
-		logger.Error("failed to initialize manticore client", sl.Err(err))
+		logger.Error("failed to initialize storage", slog.String("driver", cfg.Storage.Driver), sl.Err(err))
 		os.Exit(1)
 	}
+	defer func() {
+		if err := factory.Close(storage); err != nil {
+			logger.Error("failed to close storage", sl.Err(err))
+		}
+	}()
+
+	// В пробном запуске записи ищутся в настроенном хранилище,
+	// а сохраняются только в памяти
+	store := storage
+	var dry *memory.Storage
+	if dryRun {
+		if demon {
+			logger.Error("dry run is not supported in service mode")
+			os.Exit(1)
+		}
+		dry = memory.New(storage)
+		store = dry
+		disableSideEffects(cfg)
+		resume = false
+	}
+
+	entries := feed.NewFeedStorage(store)
 
-	storage = manticoreClient
-	entries := feed.NewFeedStorage(storage)
+	// Команда reparse: повторный разбор лент из архива ответов
+	if flag.Arg(0) == "reparse" {
+		at, err := parseArchivedAt(archivedAt)
+		if err != nil {
+			logger.Error("invalid archived-at", sl.Err(err))
+			os.Exit(1)
+		}
+		reparse(ctx, logger, cfg, entries, pageCount, at)
+		return
+	}
 
 	if demon {
 		//ch := make(chan feed.Entry, 100)
@@ -59,8 +109,9 @@ func main() {
 
 		go func() {
 			crawler.Crawler{
-				Config: cfg,
-				Logger: logger,
+				Config:  cfg,
+				Logger:  logger,
+				Entries: entries,
 			}.Run(ctx, wg)
 			// Обрабатываем ошибку и выходим с кодом 1, для того чтобы инициировать перезапуск докер контейнера.
 			// Возможно тут имеет смысл сделать сервис проверки health, но пока так
@@ -78,11 +129,36 @@ func main() {
 		cfg.PageCount = pageCount
 	}
 
+	var checkpoints *checkpoint.Store
+	if resume {
+		checkpoints, err = checkpoint.New(cfg.CheckpointPath)
+		if err != nil {
+			logger.Error("failed to open checkpoint store", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+
+	clients, err := crawler.NewClients(cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize http clients", sl.Err(err))
+		os.Exit(1)
+	}
+	defer clients.Close()
+
+	parsers := make([]*parser.Parser, 0, len(cfg.StartURLs))
 	for _, uri := range cfg.StartURLs {
 		prs := parser.New(uri, cfg, entries)
-		prs.Parse(ctx, logger)
+		clients.Apply(&prs)
+		prs.Checkpoints = checkpoints
+		prs.Resume = resume
+		parsers = append(parsers, &prs)
 	}
+	parser.ParseAll(ctx, logger, parsers, cfg.MaxConcurrentFeeds)
 	logger.Info("all pages were successfully parsed")
+
+	if dry != nil {
+		printDryRunSummary(os.Stdout, dry.Changes())
+	}
 }
 
 // setupLogger инициализирует и возвращает logger в зависимости от окружения.
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/terratensor/kremlin-parser/internal/config"
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/fetcher"
+	"github.com/terratensor/kremlin-parser/internal/lib/ratelimit"
+	"github.com/terratensor/kremlin-parser/internal/parser"
+)
+
+// reparse повторно разбирает ленты из архива ответов http.archive_path,
+// не обращаясь к сайту. Сохраненные записи обновляются, даже если время их
+// обновления не изменилось, поэтому исправление парсера применяется ко всем
+// ранее загруженным страницам. Если количество страниц не задано флагом -p,
+// разбираются все страницы ленты, которые есть в архиве. Если задано at,
+// разбираются версии страниц, полученные не позже at, иначе последние. В архиве только
+// страницы ленты, поэтому материалы и вложения не загружаются, а текст,
+// таксономия и вложения, полученные ранее со страниц материалов, переносятся
+// в обновленные записи.
+func reparse(ctx context.Context, logger *slog.Logger, cfg *config.Config, entries *feed.Entries, pageCount int, at time.Time) {
+	logger = logger.With(slog.String("archive", cfg.HTTP.ArchivePath))
+	logger.Info("reparsing archived pages")
+
+	cfg.PageCount = math.MaxInt
+	if pageCount > 0 {
+		cfg.PageCount = pageCount
+	}
+	cfg.Strategy = config.StrategyPages
+
+	replay := fetcher.NewReplay(fetcher.NewArchive(cfg.HTTP.ArchivePath))
+	replay.At = at
+	// Запросы к архиву не нагружают сайт, поэтому пауза между ними не нужна
+	limiter := ratelimit.New(0)
+
+	parsers := make([]*parser.Parser, 0, len(cfg.StartURLs))
+	for _, uri := range cfg.StartURLs {
+		prs := parser.New(uri, cfg, entries)
+		prs.Limiter = limiter
+		prs.Fetcher = replay
+		prs.Force = true
+		prs.FetchArticles = false
+		prs.DownloadAttachments = false
+		parsers = append(parsers, &prs)
+	}
+	parser.ParseAll(ctx, logger, parsers, cfg.MaxConcurrentFeeds)
+	logger.Info("archived pages were reparsed")
+}
+
+// parseArchivedAt разбирает значение флага --archived-at: дату (2006-01-02),
+// которая включается в период целиком, или дату и время в формате RFC 3339.
+// Пустое значение выбирает последние версии страниц.
+func parseArchivedAt(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
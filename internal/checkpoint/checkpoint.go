@@ -0,0 +1,97 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/terratensor/kremlin-parser/internal/lib/atomicfile"
+)
+
+// Checkpoint состояние обхода ленты для одного начального адреса.
+type Checkpoint struct {
+	StartURL string `json:"start_url"`
+	// Self адрес последней успешно обработанной страницы, Next — адрес следующей страницы.
+	// Пустой Next означает, что достигнут конец ленты.
+	Self      string    `json:"self"`
+	Next      string    `json:"next"`
+	Pages     int       `json:"pages"`
+	Inserted  int       `json:"inserted"`
+	Updated   int       `json:"updated"`
+	Unchanged int       `json:"unchanged"`
+	Failed    int       `json:"failed"`
+	SavedAt   time.Time `json:"saved_at"`
+}
+
+// Done сообщает, что обход ленты завершен.
+func (c Checkpoint) Done() bool {
+	return c.Pages > 0 && c.Next == ""
+}
+
+// Store хранилище контрольных точек в json файле.
+// Безопасно для одновременного использования несколькими парсерами.
+type Store struct {
+	path   string
+	mu     sync.Mutex
+	points map[string]Checkpoint
+}
+
+// New открывает хранилище контрольных точек в файле path.
+// Если файла нет, хранилище создается пустым.
+func New(path string) (*Store, error) {
+	const op = "checkpoint.New"
+
+	s := &Store{
+		path:   path,
+		points: make(map[string]Checkpoint),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := json.Unmarshal(data, &s.points); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// Get возвращает контрольную точку начального адреса startURL.
+func (s *Store) Get(startURL string) (Checkpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.points[startURL]
+	return c, ok
+}
+
+// Save сохраняет контрольную точку и атомарно перезаписывает файл хранилища,
+// поэтому при аварийном завершении файл остается в согласованном состоянии.
+func (s *Store) Save(c Checkpoint) error {
+	const op = "checkpoint.Save"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.SavedAt = time.Now()
+	s.points[c.StartURL] = c
+
+	data, err := json.MarshalIndent(s.points, "", "\t")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := atomicfile.WriteFile(s.path, data); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"fmt"
+	"github.com/terratensor/kremlin-parser/internal/config"
+	"github.com/terratensor/kremlin-parser/internal/fetcher"
+	"github.com/terratensor/kremlin-parser/internal/httpcache"
+	"github.com/terratensor/kremlin-parser/internal/lib/ratelimit"
+	"github.com/terratensor/kremlin-parser/internal/parser"
+	"github.com/terratensor/kremlin-parser/internal/robots"
+	"github.com/terratensor/kremlin-parser/internal/warc"
+	"log/slog"
+	"path/filepath"
+)
+
+// Clients загрузчик, правила robots.txt и валидаторы страниц, общие для всех
+// парсеров запуска. Создается один раз и для разового обхода, и для службы.
+type Clients struct {
+	// Limiter общий для всех лент, чтобы пауза между запросами к хосту
+	// соблюдалась и при параллельном парсинге, и на стыке проходов службы
+	Limiter *ratelimit.Limiter
+	Fetcher fetcher.Fetcher
+	// Pages загрузчик страниц ленты: Fetcher, а если включена запись ответов
+	// (http.record) — Recorder поверх него
+	Pages fetcher.Fetcher
+	// Robots nil, если robots.txt не учитывается
+	Robots *robots.Checker
+	// Cache nil, если условные запросы отключены
+	Cache *httpcache.Store
+	warc  *warc.Writer
+}
+
+// NewClients создает загрузчик с записью ответов и веб-архивом, проверку
+// robots.txt и кэш валидаторов по настройкам cfg. В log пишутся ошибки
+// записи в веб-архив, которые не прерывают парсинг.
+func NewClients(cfg *config.Config, log *slog.Logger) (*Clients, error) {
+	const op = "crawler.NewClients"
+
+	c := &Clients{Limiter: ratelimit.New(*cfg.ParseDelay)}
+
+	var client fetcher.Fetcher
+	client, err := fetcher.New(cfg.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("%s: http client: %w", op, err)
+	}
+
+	// Веб-архив всех загруженных страниц ленты, материалов и вложений
+	if cfg.WARC.Enabled {
+		c.warc, err = warc.NewWriter(filepath.Join(cfg.OutputPath, "warc"), cfg.WARC.Prefix, cfg.WARC.MaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("%s: warc writer: %w", op, err)
+		}
+		client = fetcher.NewWARC(client, c.warc, log)
+	}
+	c.Fetcher = client
+
+	// В архив для повторного разбора записываются только страницы ленты
+	c.Pages = client
+	if cfg.HTTP.Record {
+		c.Pages = fetcher.NewRecorder(client, fetcher.NewArchive(cfg.HTTP.ArchivePath))
+	}
+
+	if cfg.RespectRobots {
+		c.Robots = robots.New(client, cfg.HTTP.UserAgent, c.Limiter)
+	}
+
+	// Валидаторы страниц позволяют не загружать заново не изменившиеся страницы ленты
+	if cfg.ConditionalGet {
+		c.Cache, err = httpcache.New(cfg.CachePath)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("%s: http cache: %w", op, err)
+		}
+	}
+
+	return c, nil
+}
+
+// Apply передает парсеру общие загрузчик, ограничитель, robots.txt и кэш.
+func (c *Clients) Apply(p *parser.Parser) {
+	p.Limiter = c.Limiter
+	p.Fetcher = c.Fetcher
+	p.Pages = c.Pages
+	p.Robots = c.Robots
+	p.Cache = c.Cache
+}
+
+// Close закрывает веб-архив, если он ведется.
+func (c *Clients) Close() error {
+	if c.warc == nil {
+		return nil
+	}
+	return c.warc.Close()
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/terratensor/kremlin-parser/internal/entities/feed"
 	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
 	"github.com/terratensor/kremlin-parser/internal/parser"
-	"github.com/terratensor/kremlin-parser/internal/storage/manticore"
 	"log/slog"
 	"os"
 	"sync"
@@ -16,38 +15,37 @@ import (
 // Crawler is used as configuration for Run.
 // Is validated in Run().
 type Crawler struct {
-	Config  *config.Config
-	Logger  *slog.Logger
+	Config *config.Config
+	Logger *slog.Logger
+	// Entries хранилище записей, общее с остальной программой
+	Entries *feed.Entries
 	Verbose bool // Optional. If set, status updates are written to logger.
 }
 
 func (c Crawler) Run(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	var storage feed.StorageInterface
-
-	manticoreClient, err := manticore.New(c.Config.ManticoreIndex)
+	clients, err := NewClients(c.Config, c.Logger)
 	if err != nil {
-		c.Logger.Error("failed to initialize manticore client", sl.Err(err))
+		c.Logger.Error("failed to initialize http clients", sl.Err(err))
 		os.Exit(1)
 	}
-
-	storage = manticoreClient
-	entries := feed.NewFeedStorage(storage)
+	defer clients.Close()
 
 	for {
 
+		parsers := make([]*parser.Parser, 0, len(c.Config.StartURLs))
 		for _, uri := range c.Config.StartURLs {
-			prs := parser.New(uri, c.Config, entries)
-			prs.Parse(ctx, c.Logger)
+			prs := parser.New(uri, c.Config, c.Entries)
+			clients.Apply(&prs)
+			parsers = append(parsers, &prs)
 		}
+		parser.ParseAll(ctx, c.Logger, parsers, c.Config.MaxConcurrentFeeds)
 
 		select {
 		case <-ctx.Done():
-			break
-		default:
+			return
+		case <-time.After(*c.Config.TimeDelay):
 		}
-
-		time.Sleep(*c.Config.TimeDelay)
 	}
 }
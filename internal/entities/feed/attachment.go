@@ -0,0 +1,23 @@
+package feed
+
+// Виды вложений записи.
+const (
+	AttachmentPhoto    = "photo"
+	AttachmentVideo    = "video"
+	AttachmentAudio    = "audio"
+	AttachmentDocument = "document"
+	AttachmentOther    = "other"
+)
+
+// Attachment медиа вложение записи: фотография, видео или прикрепленный документ.
+type Attachment struct {
+	Url      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	Caption  string `json:"caption"`
+	Kind     string `json:"kind"`
+	// Digest и Path заполняются после загрузки вложения:
+	// sha256 содержимого и путь к файлу относительно каталога вывода парсера
+	Digest string `json:"digest,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
@@ -2,28 +2,128 @@ package feed
 
 import (
 	"golang.org/x/net/context"
+	"hash/fnv"
+	"strings"
 	"time"
 )
 
 type Entry struct {
-	ID         *int64     `json:"id"`
-	Language   string     `json:"language"`
-	Title      string     `json:"title"`
-	Url        string     `json:"url"`
-	Updated    *time.Time `json:"updated"`
-	Published  *time.Time `json:"published"`
-	Summary    string     `json:"summary"`
-	Content    string     `json:"content"`
-	Author     string     `json:"author"`
-	Number     string     `json:"number"`
-	ResourceID int        `json:"resource_id"`
+	ID          *int64       `json:"id"`
+	Language    string       `json:"language"`
+	Title       string       `json:"title"`
+	Url         string       `json:"url"`
+	Updated     *time.Time   `json:"updated"`
+	Published   *time.Time   `json:"published"`
+	Summary     string       `json:"summary"`
+	Content     string       `json:"content"`
+	Author      string       `json:"author"`
+	Number      string       `json:"number"`
+	ResourceID  int          `json:"resource_id"`
+	Categories  []string     `json:"categories"` // рубрики (темы) записи
+	Tags        []string     `json:"tags"`       // ключевые слова
+	Persons     []string     `json:"persons"`    // упомянутые персоны
+	Regions     []string     `json:"regions"`    // регионы и страны
+	Attachments []Attachment `json:"attachments"`
+	// EventKey канонический ключ события, общий для языковых версий записи, см. EventKey.
+	// CounterpartUrl и CounterpartID — адрес и идентификатор версии записи на другом языке.
+	EventKey       string `json:"event_key"`
+	CounterpartUrl string `json:"counterpart_url"`
+	CounterpartID  *int64 `json:"counterpart_id"`
+}
+
+// TermID возвращает числовой идентификатор значения таксономии (персоны, региона, тега).
+//
+// Идентификатор не зависит от регистра и лишних пробелов и используется хранилищами,
+// которые поддерживают только числовые множественные атрибуты (например, MVA в мантикоре).
+func TermID(term string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.ToLower(strings.Join(strings.Fields(term), " "))))
+	// Старший бит сбрасываем, чтобы идентификатор был положительным
+	return int64(h.Sum64() &^ (1 << 63))
+}
+
+// TermIDs возвращает идентификаторы значений таксономии, см. TermID.
+func TermIDs(terms []string) []int64 {
+	ids := make([]int64, 0, len(terms))
+	for _, t := range terms {
+		ids = append(ids, TermID(t))
+	}
+	return ids
 }
 
 type StorageInterface interface {
 	FindByUrl(ctx context.Context, url string) (*Entry, error)
 	Insert(ctx context.Context, entry *Entry) (*int64, error)
 	Update(ctx context.Context, entry *Entry) error
+	// Bulk сохраняет записи: записи без идентификатора добавляет, записи
+	// с идентификатором заменяют запись с этим идентификатором. Записи по адресам
+	// не ищутся, идентификаторы сохраненных записей передает вызывающий.
+	// Идентификаторы сохраненных записей заполняются в entries. Если часть записей
+	// не сохранена, возвращается ошибка *storage.BulkError с ошибками этих записей.
 	Bulk(ctx context.Context, entries *[]Entry) error
+	// FindByEventKey возвращает все языковые версии события с ключом key.
+	FindByEventKey(ctx context.Context, key string) ([]Entry, error)
+	// FindByID возвращает запись с идентификатором id или nil, если записи нет.
+	FindByID(ctx context.Context, id int64) (*Entry, error)
+	// FindByUrls возвращает найденные записи с адресами urls в произвольном порядке.
+	FindByUrls(ctx context.Context, urls []string) ([]Entry, error)
+	// List возвращает не больше limit записей, подходящих под filter,
+	// с идентификаторами больше after в порядке возрастания идентификаторов.
+	// Для первой страницы after равен 0, для следующих — ID последней записи
+	// предыдущей страницы. Страница короче limit означает, что записей больше нет.
+	List(ctx context.Context, filter Filter, after int64, limit int) ([]Entry, error)
+	// Count возвращает количество записей, подходящих под filter.
+	Count(ctx context.Context, filter Filter) (int64, error)
+	// Delete удаляет запись с идентификатором id.
+	// Если записи нет, возвращается ошибка storage.ErrNotFound.
+	Delete(ctx context.Context, id int64) error
+}
+
+// Filter условия выборки записей для List и Count. Пустые поля выборку не ограничивают.
+type Filter struct {
+	Language   string
+	ResourceID int
+	// Since и Until ограничивают дату публикации: Since <= Published < Until.
+	// Записи без даты публикации под ограничение по дате не подходят.
+	Since *time.Time
+	Until *time.Time
+}
+
+// Match сообщает, подходит ли запись под условия фильтра.
+// Используется хранилищами, которые отбирают записи сами, а не запросом к базе.
+func (f Filter) Match(e *Entry) bool {
+	if f.Language != "" && e.Language != f.Language {
+		return false
+	}
+	if f.ResourceID != 0 && e.ResourceID != f.ResourceID {
+		return false
+	}
+	if f.Since != nil || f.Until != nil {
+		if e.Published == nil {
+			return false
+		}
+		if f.Since != nil && e.Published.Before(*f.Since) {
+			return false
+		}
+		if f.Until != nil && !e.Published.Before(*f.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// IDAssigner реализуют хранилища, которые принимают идентификаторы новых
+// записей от клиента. Парсер назначает их до сохранения, поэтому ссылки
+// языковых версий на новые записи сохраняются в том же вызове Bulk.
+type IDAssigner interface {
+	// AssignID возвращает идентификатор для новой записи e.
+	AssignID(e *Entry) int64
+}
+
+// Syncer реализуют хранилища, которые буферизуют записи.
+// Парсер вызывает Sync после сохранения каждой страницы ленты.
+type Syncer interface {
+	Sync() error
 }
 
 type Entries struct {
@@ -0,0 +1,60 @@
+package feed
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// languageHosts поддомены языковых версий сайта, которые не входят в ключ события.
+var languageHosts = []string{"en."}
+
+// eventIDRe находит числовой идентификатор события в конце пути адреса записи.
+var eventIDRe = regexp.MustCompile(`/(\d+)/?$`)
+
+// EventKey возвращает канонический ключ события по адресу записи.
+//
+// Русская и английская версии одного события на kremlin.ru имеют одинаковый путь
+// и отличаются только поддоменом: kremlin.ru/events/president/news/73568 и
+// en.kremlin.ru/events/president/news/73568. Ключ составляется из домена без
+// языкового поддомена и пути с числовым идентификатором события.
+// Если в пути нет числового идентификатора, возвращается пустая строка.
+func EventKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !eventIDRe.MatchString(u.Path) {
+		return ""
+	}
+	return baseHost(u.Hostname()) + "/" + strings.Trim(u.Path, "/")
+}
+
+// CounterpartURL возвращает адрес версии записи на другом языке:
+// для kremlin.ru — en.kremlin.ru и наоборот.
+func CounterpartURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || EventKey(rawURL) == "" {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	base := baseHost(host)
+	if host == base {
+		host = languageHosts[0] + base
+	} else {
+		host = base
+	}
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	return u.String()
+}
+
+// baseHost возвращает домен без www и языкового поддомена.
+func baseHost(host string) string {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for _, p := range languageHosts {
+		host = strings.TrimPrefix(host, p)
+	}
+	return host
+}
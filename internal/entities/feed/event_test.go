@@ -0,0 +1,45 @@
+package feed
+
+import "testing"
+
+func TestEventKey(t *testing.T) {
+	for _, c := range []struct {
+		url  string
+		want string
+	}{
+		{"http://kremlin.ru/events/president/news/73568", "kremlin.ru/events/president/news/73568"},
+		{"http://en.kremlin.ru/events/president/news/73568", "kremlin.ru/events/president/news/73568"},
+		{"https://www.kremlin.ru/events/president/news/73568/", "kremlin.ru/events/president/news/73568"},
+		{"http://kremlin.ru:8080/acts/news/1", "kremlin.ru/acts/news/1"},
+		{"http://kremlin.ru/events/president/news", ""},
+		{"http://kremlin.ru/events/president/news/73568/photos", ""},
+		{"::not a url", ""},
+	} {
+		if got := EventKey(c.url); got != c.want {
+			t.Errorf("EventKey(%q) = %q, want %q", c.url, got, c.want)
+		}
+	}
+
+	ru := EventKey("http://kremlin.ru/events/president/news/73568")
+	en := EventKey("http://en.kremlin.ru/events/president/news/73568")
+	if ru != en {
+		t.Errorf("language versions have different keys: %q and %q", ru, en)
+	}
+}
+
+func TestCounterpartURL(t *testing.T) {
+	for _, c := range []struct {
+		url  string
+		want string
+	}{
+		{"http://kremlin.ru/events/president/news/73568", "http://en.kremlin.ru/events/president/news/73568"},
+		{"http://en.kremlin.ru/events/president/news/73568", "http://kremlin.ru/events/president/news/73568"},
+		{"http://www.kremlin.ru/acts/news/1", "http://en.kremlin.ru/acts/news/1"},
+		{"http://en.kremlin.ru:8080/events/president/news/1", "http://kremlin.ru:8080/events/president/news/1"},
+		{"http://kremlin.ru/events/president/news", ""},
+	} {
+		if got := CounterpartURL(c.url); got != c.want {
+			t.Errorf("CounterpartURL(%q) = %q, want %q", c.url, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/terratensor/kremlin-parser/internal/lib/atomicfile"
+)
+
+// ErrNotArchived возвращается Replay, если ответа на запрос нет в архиве.
+var ErrNotArchived = errors.New("response not archived")
+
+// Record метаданные сохраненного в архиве ответа.
+type Record struct {
+	URL       string      `json:"url"`
+	Status    int         `json:"status"`
+	Header    http.Header `json:"header"`
+	FetchedAt time.Time   `json:"fetched_at"`
+}
+
+// Archive архив необработанных ответов сервера в каталоге dir.
+//
+// Каждый ответ хранится отдельной версией: метаданные в файле
+// ab/<sha256>/<время>.json и тело в файле ab/<sha256>/<время>.body, где
+// sha256 — хеш адреса, а время — время получения ответа в UTC. Поэтому
+// страницы ленты можно разобрать в том виде, в котором они были получены
+// в любой из обходов, даже после того как записи сместились на другие
+// страницы. Тело сохраняется в том виде, в котором его получает парсер,
+// то есть уже распакованным.
+type Archive struct {
+	dir string
+}
+
+// versionLayout формат времени в именах файлов версий, имена сортируются по времени.
+const versionLayout = "20060102T150405.000000000Z"
+
+// NewArchive открывает архив в каталоге dir, каталог создается при первой записи.
+func NewArchive(dir string) *Archive {
+	return &Archive{dir: dir}
+}
+
+// path возвращает каталог версий ответов на запрос url.
+func (a *Archive) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(a.dir, name[:2], name)
+}
+
+// Save сохраняет ответ в архив новой версией со временем rec.FetchedAt.
+// Тело записывается раньше метаданных, поэтому прерванная запись не оставляет
+// в архиве неполных ответов.
+func (a *Archive) Save(rec Record, body []byte) error {
+	const op = "fetcher.Archive.Save"
+
+	path := filepath.Join(a.path(rec.URL), rec.FetchedAt.UTC().Format(versionLayout))
+	if err := atomicfile.WriteFile(path+".body", body); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := atomicfile.WriteFile(path+".json", data); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Versions возвращает время получения сохраненных ответов на запрос url
+// в порядке возрастания.
+func (a *Archive) Versions(url string) ([]time.Time, error) {
+	const op = "fetcher.Archive.Versions"
+
+	files, err := os.ReadDir(a.path(url))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Файлы возвращаются отсортированными по имени, то есть по времени
+	var versions []time.Time
+	for _, f := range files {
+		name, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(versionLayout, name)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, t)
+	}
+
+	return versions, nil
+}
+
+// Load возвращает последний ответ на запрос url, полученный не позже at,
+// или ErrNotArchived. Нулевое at выбирает последний сохраненный ответ.
+func (a *Archive) Load(url string, at time.Time) (*Record, []byte, error) {
+	const op = "fetcher.Archive.Load"
+
+	versions, err := a.Versions(url)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	i := len(versions) - 1
+	for !at.IsZero() && i >= 0 && versions[i].After(at) {
+		i--
+	}
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%s: %w: %s", op, ErrNotArchived, url)
+	}
+
+	path := filepath.Join(a.path(url), versions[i].Format(versionLayout))
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	body, err := os.ReadFile(path + ".body")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &rec, body, nil
+}
+
+// Recorder Fetcher, который сохраняет в архив ответы 200 OK вложенного Fetcher.
+// Остальные ответы (304, 429, 5xx) не сохраняются, чтобы не затереть ранее
+// сохраненный ответ и не воспроизводить временную ошибку при повторном разборе.
+type Recorder struct {
+	next    Fetcher
+	archive *Archive
+}
+
+var _ Fetcher = &Recorder{}
+
+// NewRecorder создает Recorder, сохраняющий ответы next в archive.
+func NewRecorder(next Fetcher, archive *Archive) *Recorder {
+	return &Recorder{next: next, archive: archive}
+}
+
+// Fetch выполняет запрос через вложенный Fetcher и сохраняет ответ 200 OK в архив.
+// Тело ответа читается целиком, вызывающий получает его копию.
+func (r *Recorder) Fetch(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	const op = "fetcher.Recorder.Fetch"
+
+	resp, err := r.next.Fetch(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rec := Record{
+		URL:       url,
+		Status:    resp.StatusCode,
+		Header:    resp.Header,
+		FetchedAt: time.Now(),
+	}
+	if err := r.archive.Save(rec, body); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// Replay Fetcher, который отдает ответы из архива, не обращаясь к сети.
+// Заголовки запроса не учитываются, поэтому условные запросы всегда
+// получают сохраненный ответ целиком.
+type Replay struct {
+	archive *Archive
+	// At выбирает версию ответа: последнюю, полученную не позже At.
+	// Если не задано, отдается последний сохраненный ответ
+	At time.Time
+}
+
+var _ Fetcher = &Replay{}
+
+// NewReplay создает Replay, отдающий ответы из archive.
+func NewReplay(archive *Archive) *Replay {
+	return &Replay{archive: archive}
+}
+
+// Fetch возвращает сохраненный ответ на запрос url или ошибку ErrNotArchived.
+func (r *Replay) Fetch(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rec, body, err := r.archive.Load(url, r.At)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
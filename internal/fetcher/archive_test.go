@@ -0,0 +1,64 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestArchiveVersions(t *testing.T) {
+	a := NewArchive(t.TempDir())
+	url := "http://kremlin.ru/events/president/news/feed/page/2"
+	first := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	for _, v := range []struct {
+		at   time.Time
+		body string
+	}{
+		{first, "first"},
+		{second, "second"},
+	} {
+		rec := Record{URL: url, Status: http.StatusOK, Header: http.Header{}, FetchedAt: v.at}
+		if err := a.Save(rec, []byte(v.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := a.Versions(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || !versions[0].Equal(first) || !versions[1].Equal(second) {
+		t.Fatalf("Versions() = %v, want [%v %v]", versions, first, second)
+	}
+
+	for _, c := range []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Time{}, "second"},
+		{second, "second"},
+		{second.Add(-time.Second), "first"},
+	} {
+		replay := NewReplay(a)
+		replay.At = c.at
+		resp, err := replay.Fetch(context.Background(), url, nil)
+		if err != nil {
+			t.Fatalf("at %v: %v", c.at, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != c.want {
+			t.Errorf("at %v: got body %q, want %q", c.at, body, c.want)
+		}
+	}
+
+	replay := NewReplay(a)
+	replay.At = first.Add(-time.Second)
+	if _, err := replay.Fetch(context.Background(), url, nil); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("before first version: got %v, want %v", err, ErrNotArchived)
+	}
+}
@@ -0,0 +1,246 @@
+package fetcher
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/terratensor/kremlin-parser/internal/config"
+)
+
+// ErrBodyTooLarge возвращается при чтении тела ответа, размер которого превышает MaxBodySize.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// Fetcher загружает ресурсы по HTTP. Парсер делает все запросы через Fetcher,
+// поэтому в тестах его можно заменить реализацией, которая не обращается к сети.
+type Fetcher interface {
+	// Fetch выполняет GET запрос по адресу url с дополнительными заголовками header.
+	// Вызывающий обязан закрыть тело ответа.
+	Fetch(ctx context.Context, url string, header http.Header) (*http.Response, error)
+}
+
+// Client реализация Fetcher на основе http.Client, настраиваемая из config.HTTP.
+// Тела ответов, сжатые gzip или brotli, распаковываются прозрачно.
+type Client struct {
+	client      *http.Client
+	userAgent   string
+	headers     http.Header
+	maxBodySize int64
+}
+
+var _ Fetcher = &Client{}
+
+// New создает Client по настройкам HTTP клиента из конфига.
+func New(cfg config.HTTP) (*Client, error) {
+	const op = "fetcher.New"
+
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	// Сжатие обрабатывается в Fetch, чтобы поддержать brotli
+	transport.DisableCompression = true
+
+	// Поддерживаются прокси http://, https:// и socks5://
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%s: proxy: %w", op, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	headers := http.Header{}
+	for k, v := range cfg.Headers {
+		headers.Set(k, v)
+	}
+
+	c := &Client{
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+		},
+		userAgent:   cfg.UserAgent,
+		headers:     headers,
+		maxBodySize: cfg.MaxBodySize,
+	}
+
+	return c, nil
+}
+
+// Fetch выполняет GET запрос. Заголовки header имеют приоритет перед
+// заголовками из конфига.
+func (c *Client) Fetch(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	resp, err := c.fetchRaw(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.decode(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// rawFetcher Fetcher, который может вернуть ответ с телом в том виде,
+// в котором оно передано по сети, и распаковать его отдельно.
+// Используется WARC, чтобы записывать в архив ответ без изменений.
+type rawFetcher interface {
+	fetchRaw(ctx context.Context, url string, header http.Header) (*http.Response, error)
+	decode(resp *http.Response) error
+}
+
+var _ rawFetcher = &Client{}
+
+// fetchRaw выполняет GET запрос и возвращает ответ без распаковки тела.
+func (c *Client) fetchRaw(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Без user-agent kremlin.ru не отдает данные
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	for k, v := range c.headers {
+		req.Header[k] = v
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	return c.client.Do(req)
+}
+
+// decode распаковывает тело ответа, полученного fetchRaw, и ограничивает
+// его размер MaxBodySize.
+func (c *Client) decode(resp *http.Response) error {
+	if err := decodeBody(resp); err != nil {
+		return err
+	}
+	if c.maxBodySize > 0 {
+		resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: c.maxBodySize}
+	}
+	return nil
+}
+
+// decodeBody подменяет тело ответа распаковывающим читателем
+// в соответствии с заголовком Content-Encoding.
+func decodeBody(resp *http.Response) error {
+	var r io.Reader
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "":
+		return nil
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			// Пустое тело, например у ответа 304, не является ошибкой
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		r = gr
+	case "br":
+		r = brotli.NewReader(resp.Body)
+	default:
+		return nil
+	}
+
+	resp.Body = &decodedBody{Reader: r, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodedBody распакованное тело ответа, Close закрывает исходное тело.
+type decodedBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	return b.body.Close()
+}
+
+// limitedBody тело ответа, чтение которого завершается ошибкой ErrBodyTooLarge
+// после remaining байт.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	// Читаем на байт больше лимита, чтобы отличить тело ровно в лимит от превышения
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), ErrBodyTooLarge
+	}
+	return n, err
+}
+
+// newTLSConfig создает настройки TLS: дополнительные корневые сертификаты,
+// клиентский сертификат, минимальная версия протокола.
+func newTLSConfig(cfg config.TLS) (*tls.Config, error) {
+	tc := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	switch cfg.MinVersion {
+	case "":
+	case "1.2":
+		tc.MinVersion = tls.VersionTLS12
+	case "1.3":
+		tc.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("tls: unsupported min version %q", cfg.MinVersion)
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates found in %s", cfg.CAFile)
+		}
+		tc.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	return tc, nil
+}
@@ -0,0 +1,89 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
+	"github.com/terratensor/kremlin-parser/internal/warc"
+)
+
+// WARC Fetcher, который записывает каждый запрос и ответ вложенного Fetcher
+// в веб-архив формата WARC.
+type WARC struct {
+	next   Fetcher
+	writer *warc.Writer
+	log    *slog.Logger
+}
+
+var _ Fetcher = &WARC{}
+
+// NewWARC создает WARC, записывающий запросы и ответы next в writer.
+// Ошибки записи в архив логируются в log и не прерывают загрузку.
+func NewWARC(next Fetcher, writer *warc.Writer, log *slog.Logger) *WARC {
+	return &WARC{next: next, writer: writer, log: log}
+}
+
+// Fetch выполняет запрос через вложенный Fetcher и записывает запрос и ответ в архив.
+// Тело ответа читается целиком, вызывающий получает его копию.
+//
+// Если вложенный Fetcher — Client, в архив записывается тело в том виде,
+// в котором оно передано по сети, вместе с заголовками Content-Encoding
+// и Content-Length, а распаковывается только копия для вызывающего.
+func (w *WARC) Fetch(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	raw, ok := w.next.(rawFetcher)
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	if ok {
+		resp, err = raw.fetchRaw(ctx, url, header)
+	} else {
+		resp, err = w.next.Fetch(ctx, url, header)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	w.write(ctx, url, header, resp, body)
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if ok {
+		if err := raw.decode(resp); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// write записывает запрос и ответ с телом body в архив, ошибка записи логируется.
+func (w *WARC) write(ctx context.Context, url string, header http.Header, resp *http.Response, body []byte) {
+	// У ответов, полученных не по сети, запроса нет, в архив пишется
+	// запрос с адресом и заголовками вызывающего
+	req := resp.Request
+	if req == nil {
+		var err error
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			w.log.Error("failed to write warc record", slog.String("url", url), sl.Err(err))
+			return
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+	}
+
+	if err := w.writer.WriteExchange(req, resp, body); err != nil {
+		w.log.Error("failed to write warc record", slog.String("url", url), sl.Err(err))
+	}
+}
@@ -0,0 +1,127 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/base32"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/terratensor/kremlin-parser/internal/config"
+	"github.com/terratensor/kremlin-parser/internal/warc"
+)
+
+func TestWARCRecordsTransferredBody(t *testing.T) {
+	const page = "<feed>страница ленты</feed>"
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(page))
+	_ = gz.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.Itoa(compressed.Len()))
+		_, _ = w.Write(compressed.Bytes())
+	}))
+	defer srv.Close()
+
+	client, err := New(config.HTTP{UserAgent: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writer, err := warc.NewWriter(dir, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewWARC(client, writer, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	resp, err := f.Fetch(context.Background(), srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != page {
+		t.Errorf("caller got body %q, want decoded %q", body, page)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.warc.gz"))
+	if len(files) != 1 {
+		t.Fatalf("got %d warc files, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha1.Sum(compressed.Bytes())
+	for _, want := range []string{
+		"Content-Encoding: gzip\r\n",
+		"Content-Length: " + strconv.Itoa(compressed.Len()) + "\r\n",
+		"WARC-Payload-Digest: sha1:" + base32.StdEncoding.EncodeToString(sum[:]) + "\r\n",
+	} {
+		if !strings.Contains(string(records), want) {
+			t.Errorf("warc records do not contain %q", want)
+		}
+	}
+	if !bytes.Contains(records, compressed.Bytes()) {
+		t.Error("warc response record does not contain the transferred body")
+	}
+}
+
+func TestWARCWriteErrorDoesNotFailFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	client, err := New(config.HTTP{UserAgent: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "warc")
+	writer, err := warc.NewWriter(dir, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Файл архива не удастся создать
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	f := NewWARC(client, writer, slog.New(slog.NewTextHandler(&logs, nil)))
+	resp, err := f.Fetch(context.Background(), srv.URL, nil)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v, want response", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("got body %q, want %q", body, "ok")
+	}
+	if !strings.Contains(logs.String(), "failed to write warc record") {
+		t.Errorf("warc write error is not logged: %s", logs.String())
+	}
+}
@@ -0,0 +1,128 @@
+package httpcache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/terratensor/kremlin-parser/internal/lib/atomicfile"
+)
+
+// Validators валидаторы ответа для условного GET запроса
+// и адрес следующей страницы ленты, который нужен, чтобы продолжить
+// обход ленты, когда сервер ответил 304 Not Modified.
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Next         string `json:"next,omitempty"`
+}
+
+// Empty сообщает, что у ответа нет ни одного валидатора.
+func (v Validators) Empty() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// FromResponse возвращает валидаторы из заголовков ответа.
+func FromResponse(resp *http.Response) Validators {
+	return Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+}
+
+// SetHeaders добавляет в запрос заголовки If-None-Match и If-Modified-Since.
+func (v Validators) SetHeaders(h http.Header) {
+	if v.ETag != "" {
+		h.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		h.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+// Store хранилище валидаторов по адресам страниц в json файле.
+// Безопасно для одновременного использования несколькими парсерами.
+type Store struct {
+	path  string
+	mu    sync.Mutex
+	dirty bool
+	items map[string]Validators
+}
+
+// New открывает хранилище валидаторов в файле path.
+// Если файла нет, хранилище создается пустым.
+func New(path string) (*Store, error) {
+	const op = "httpcache.New"
+
+	s := &Store{
+		path:  path,
+		items: make(map[string]Validators),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := json.Unmarshal(data, &s.items); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// Get возвращает валидаторы страницы url.
+func (s *Store) Get(url string) (Validators, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.items[url]
+	return v, ok
+}
+
+// Set запоминает валидаторы страницы url. На диск изменения записываются в Flush.
+func (s *Store) Set(url string, v Validators) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v.Empty() {
+		if _, ok := s.items[url]; ok {
+			delete(s.items, url)
+			s.dirty = true
+		}
+		return
+	}
+	if s.items[url] != v {
+		s.items[url] = v
+		s.dirty = true
+	}
+}
+
+// Flush атомарно записывает хранилище в файл, если оно изменилось.
+func (s *Store) Flush() error {
+	const op = "httpcache.Flush"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.items, "", "\t")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := atomicfile.WriteFile(s.path, data); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter ограничивает частоту запросов к каждому хосту отдельно:
+// между запросами к одному хосту выдерживается пауза не меньше delay,
+// запросы к разным хостам друг друга не ждут.
+// Безопасен для одновременного использования несколькими парсерами.
+type Limiter struct {
+	mu    sync.Mutex
+	delay time.Duration
+	hosts map[string]*host
+}
+
+type host struct {
+	// next время, раньше которого нельзя делать следующий запрос к хосту
+	next  time.Time
+	delay time.Duration
+}
+
+// New создает Limiter с паузой delay между запросами к одному хосту.
+func New(delay time.Duration) *Limiter {
+	return &Limiter{
+		delay: delay,
+		hosts: make(map[string]*host),
+	}
+}
+
+// SetDelay устанавливает паузу для хоста, если она больше паузы по умолчанию,
+// например из директивы Crawl-delay файла robots.txt.
+func (l *Limiter) SetDelay(hostname string, delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h := l.host(strings.ToLower(hostname))
+	if delay > l.delay {
+		h.delay = delay
+	} else {
+		h.delay = l.delay
+	}
+}
+
+// Delay возвращает паузу между запросами к хосту адреса rawURL.
+func (l *Limiter) Delay(rawURL string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.host(hostname(rawURL)).delay
+}
+
+// Wait ждет, пока можно будет сделать запрос по адресу rawURL.
+// Первый запрос к хосту выполняется сразу. Возвращает ошибку контекста,
+// если ожидание было прервано.
+func (l *Limiter) Wait(ctx context.Context, rawURL string) error {
+	l.mu.Lock()
+	h := l.host(hostname(rawURL))
+	now := time.Now()
+	at := h.next
+	if at.Before(now) {
+		at = now
+	}
+	// Резервируем время запроса, чтобы параллельные запросы к хосту встали в очередь
+	h.next = at.Add(h.delay)
+	l.mu.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// host возвращает состояние хоста, создавая его при первом обращении.
+// Вызывается под l.mu.
+func (l *Limiter) host(name string) *host {
+	h, ok := l.hosts[name]
+	if !ok {
+		h = &host{delay: l.delay}
+		l.hosts[name] = h
+	}
+	return h
+}
+
+// hostname возвращает имя хоста адреса в нижнем регистре.
+func hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,252 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Селекторы блоков страницы материала на kremlin.ru.
+// Блок задается классом элемента или значением атрибута itemprop.
+var (
+	articleLeadSelectors       = []selector{{class: "read__lead"}, {itemprop: "description"}}
+	articleBodySelectors       = []selector{{itemprop: "articleBody"}, {class: "read__internal_content"}, {class: "entry-content"}}
+	articleTranscriptSelectors = []selector{{class: "read__transcript"}, {class: "transcript"}}
+)
+
+// selector простой селектор html элемента по классу или атрибуту itemprop.
+type selector struct {
+	class    string
+	itemprop string
+}
+
+// Article основные блоки страницы материала: вводный абзац,
+// текст материала и блоки стенограммы, если они вынесены за пределы текста,
+// а также ссылки на разделы каталога (персоны, регионы, ключевые слова).
+type Article struct {
+	Lead        string
+	Body        string
+	Transcripts []string
+	Terms       []term
+}
+
+// HTML возвращает разметку материала, объединяя все найденные блоки.
+func (a *Article) HTML() string {
+	var parts []string
+	for _, s := range append([]string{a.Lead, a.Body}, a.Transcripts...) {
+		if s = strings.TrimSpace(s); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// enrich загружает страницу записи и дополняет её полным текстом материала.
+//
+// Вызывается только для новых или измененных записей и только если для
+// начального адреса включена опция fetch_articles. Перед запросом делается
+// пауза, общая для всех запросов к хосту. Ошибки загрузки не прерывают
+// парсинг: запись сохраняется с текстом из ленты.
+func (p *Parser) enrich(ctx context.Context, log *slog.Logger, e *feed.Entry) {
+	if !p.FetchArticles {
+		return
+	}
+
+	if err := p.wait(ctx, log, e.Url); err != nil {
+		return
+	}
+
+	a, err := p.getArticle(ctx, e.Url)
+	if err != nil {
+		log.Error("failed to fetch article", slog.String("url", e.Url), sl.Err(err))
+		return
+	}
+
+	a.apply(e)
+	log.Debug("entry enriched from article page", slog.String("url", e.Url))
+}
+
+// apply заменяет текст записи текстом материала, если текст в ленте короче.
+// Пустая аннотация записи заполняется вводным абзацем.
+func (a *Article) apply(e *feed.Entry) {
+	if content := a.HTML(); len(textContent(content)) > len(textContent(e.Content)) {
+		e.Content = content
+	}
+	if e.Summary == "" {
+		e.Summary = textContent(a.Lead)
+	}
+	for _, t := range a.Terms {
+		addTerm(e, t)
+	}
+	extractAttachments(e, a.HTML())
+}
+
+// keepArticle переносит в запись e данные, полученные ранее со страницы
+// материала, из сохраненной версии dbe. Вызывается, если страница материала
+// в этот раз не загружалась, например при повторном разборе архива: более
+// полные текст и аннотация сохраненной версии остаются, значения таксономии
+// и вложения объединяются.
+func keepArticle(e, dbe *feed.Entry) {
+	if len(textContent(dbe.Content)) > len(textContent(e.Content)) {
+		e.Content = dbe.Content
+	}
+	if len(textContent(dbe.Summary)) > len(textContent(e.Summary)) {
+		e.Summary = dbe.Summary
+	}
+	for _, v := range dbe.Categories {
+		e.Categories = appendUnique(e.Categories, v)
+	}
+	for _, v := range dbe.Tags {
+		e.Tags = appendUnique(e.Tags, v)
+	}
+	for _, v := range dbe.Persons {
+		e.Persons = appendUnique(e.Persons, v)
+	}
+	for _, v := range dbe.Regions {
+		e.Regions = appendUnique(e.Regions, v)
+	}
+	for _, a := range dbe.Attachments {
+		addAttachment(e, a)
+	}
+}
+
+// getArticle загружает и разбирает страницу материала.
+func (p *Parser) getArticle(ctx context.Context, url string) (*Article, error) {
+	resp, err := p.Fetcher.Fetch(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	return parseArticle(resp.Body)
+}
+
+// parseArticle извлекает блоки материала из html страницы kremlin.ru.
+func parseArticle(r io.Reader) (*Article, error) {
+	const op = "parser.parseArticle"
+
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a := &Article{}
+	var f func(n *html.Node, inBody bool)
+	f = func(n *html.Node, inBody bool) {
+		if n.Type == html.ElementNode {
+			switch {
+			// Ссылки на каталог в шапке, меню и подвале сайта к материалу не относятся
+			case n.Data == "header" || n.Data == "nav" || n.Data == "footer":
+				return
+			case n.Data == "a" && catalogRe.MatchString(getRequiredDataAttr("href", n)):
+				a.Terms = append(a.Terms, term{Ref: getRequiredDataAttr("href", n), Name: textContent(renderInner(n))})
+				return
+			case a.Body == "" && matchAny(n, articleBodySelectors):
+				// Блоки внутри текста материала уже попадут в Body,
+				// поэтому внутри него ищутся только ссылки на каталог
+				a.Body = renderInner(n)
+				inBody = true
+			case inBody:
+			case a.Lead == "" && matchAny(n, articleLeadSelectors):
+				a.Lead = renderInner(n)
+			case matchAny(n, articleTranscriptSelectors):
+				a.Transcripts = append(a.Transcripts, renderInner(n))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c, inBody)
+		}
+	}
+	f(doc, false)
+
+	if a.Body == "" {
+		return nil, fmt.Errorf("%s: article body not found", op)
+	}
+
+	return a, nil
+}
+
+// matchAny проверяет, соответствует ли элемент хотя бы одному из селекторов.
+func matchAny(n *html.Node, selectors []selector) bool {
+	for _, s := range selectors {
+		if s.class != "" && nodeHasClass(n, s.class) {
+			return true
+		}
+		if s.itemprop != "" && getRequiredDataAttr("itemprop", n) == s.itemprop {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeHasClass проверяет, содержит ли атрибут class элемента указанный класс.
+func nodeHasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(getRequiredDataAttr("class", n)) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// getRequiredDataAttr returns the value of the specified attribute from the given html.Node.
+//
+// rda string - the attribute key to search for.
+// n *html.Node - the html node to search within.
+// string - the value of the specified attribute, or an empty string if not found.
+func getRequiredDataAttr(rda string, n *html.Node) string {
+	for _, attr := range n.Attr {
+		if attr.Key == rda {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// renderInner возвращает разметку дочерних узлов элемента.
+func renderInner(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		_ = html.Render(&b, c)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// textContent возвращает текст html фрагмента без разметки.
+func textContent(fragment string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		return fragment
+	}
+
+	var b strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	for _, n := range nodes {
+		f(n)
+	}
+	return strings.TrimSpace(b.String())
+}
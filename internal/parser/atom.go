@@ -0,0 +1,251 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+)
+
+const atomNS = "http://www.w3.org/2005/Atom"
+
+// atomLink элемент <link> ленты или записи Atom.
+type atomLink struct {
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+	Title  string `xml:"title,attr"`
+}
+
+// atomText текстовая конструкция Atom (title, summary, content).
+// Для type="html" разметка приходит экранированной или в CDATA и
+// после декодирования оказывается в Text, для type="xhtml" разметка
+// вложена как есть и сохраняется в InnerXML.
+type atomText struct {
+	Type     string `xml:"type,attr"`
+	Text     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+// atomPerson элемент <author> или <contributor>.
+type atomPerson struct {
+	Name  string `xml:"name"`
+	URI   string `xml:"uri"`
+	Email string `xml:"email"`
+}
+
+// atomCategory элемент <category> записи Atom.
+type atomCategory struct {
+	Term   string `xml:"term,attr"`
+	Scheme string `xml:"scheme,attr"`
+	Label  string `xml:"label,attr"`
+}
+
+// term возвращает значение таксономии категории. Если label не задан,
+// названием считается term, при условии что он не является ссылкой.
+func (c atomCategory) term() term {
+	t := term{Ref: c.Scheme + " " + c.Term, Name: c.Label}
+	if t.Name == "" && !strings.Contains(c.Term, "/") {
+		t.Name = c.Term
+	}
+	return t
+}
+
+// atomEntry элемент <entry> ленты Atom.
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      atomText       `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Summary    atomText       `xml:"summary"`
+	Content    atomText       `xml:"content"`
+	Authors    []atomPerson   `xml:"author"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// String возвращает содержимое текстовой конструкции в виде строки.
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.InnerXML)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// decodeAtom потоково декодирует ленту Atom из r.
+//
+// Ошибки отдельных элементов (например, неверный формат даты в записи)
+// не прерывают разбор: такая запись пропускается, а ошибка попадает в
+// document.Errors. Возвращаемая ошибка означает, что документ не может
+// быть разобран целиком.
+func decodeAtom(r io.Reader) (*document, error) {
+	const op = "parser.decodeAtom"
+
+	doc := &document{Format: FormatAtom}
+	d := newXMLDecoder(r)
+
+	depth := 0
+	index := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 1 {
+				if t.Name.Local != "feed" {
+					return nil, fmt.Errorf("%s: %w: <%s>", op, ErrUnknownFormat, t.Name.Local)
+				}
+				continue
+			}
+			// Интересуют только прямые потомки <feed>
+			if depth != 2 || !isAtomName(t.Name) {
+				continue
+			}
+
+			if err := doc.decodeAtomElement(d, t, &index); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			depth--
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	return doc, nil
+}
+
+// decodeAtomElement декодирует очередной дочерний элемент <feed>.
+// Возвращает ошибку только в случае синтаксической ошибки XML,
+// после которой дальнейший разбор документа невозможен.
+func (doc *document) decodeAtomElement(d *xml.Decoder, start xml.StartElement, index *int) error {
+	switch start.Name.Local {
+	case "id":
+		return d.DecodeElement(&doc.Meta.ID, &start)
+	case "updated":
+		var s string
+		if err := d.DecodeElement(&s, &start); err != nil {
+			return err
+		}
+		t, err := parseAtomTime(s)
+		if err != nil {
+			doc.Errors = append(doc.Errors, &DecodeError{Element: "feed/updated", Err: err})
+			return nil
+		}
+		doc.Meta.Updated = t
+	case "author":
+		var a atomPerson
+		if err := d.DecodeElement(&a, &start); err != nil {
+			return err
+		}
+		doc.Author = joinAuthors(doc.Author, a.Name)
+	case "link":
+		var l atomLink
+		if err := d.DecodeElement(&l, &start); err != nil {
+			return err
+		}
+		doc.Meta.setLink(l.Rel, l.Href)
+	case "entry":
+		var ae atomEntry
+		if err := d.DecodeElement(&ae, &start); err != nil {
+			return err
+		}
+		*index++
+		e, err := ae.toEntry()
+		if err != nil {
+			doc.Errors = append(doc.Errors, &DecodeError{Element: "entry", Index: *index, ID: ae.ID, Err: err})
+			return nil
+		}
+		doc.Entries = append(doc.Entries, *e)
+	default:
+		return d.Skip()
+	}
+	return nil
+}
+
+// toEntry преобразует запись Atom в feed.Entry.
+func (ae atomEntry) toEntry() (*feed.Entry, error) {
+	e := &feed.Entry{
+		Title:   ae.Title.String(),
+		Url:     strings.TrimSpace(ae.ID),
+		Summary: ae.Summary.String(),
+		Content: ae.Content.String(),
+	}
+	for _, a := range ae.Authors {
+		e.Author = joinAuthors(e.Author, a.Name)
+	}
+	for _, c := range ae.Categories {
+		addTerm(e, c.term())
+	}
+	for _, l := range ae.Links {
+		if l.Rel == "enclosure" {
+			addAttachment(e, feed.Attachment{
+				Url:      l.Href,
+				MimeType: l.Type,
+				Size:     parseLength(l.Length),
+				Caption:  l.Title,
+			})
+		}
+	}
+
+	// Если id записи не является ссылкой, берем адрес из <link rel="alternate">
+	if !strings.HasPrefix(e.Url, "http") {
+		for _, l := range ae.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				e.Url = l.Href
+				break
+			}
+		}
+	}
+
+	var err error
+	if e.Updated, err = parseAtomTime(ae.Updated); err != nil {
+		return nil, fmt.Errorf("updated: %w", err)
+	}
+	if ae.Published != "" {
+		if e.Published, err = parseAtomTime(ae.Published); err != nil {
+			return nil, fmt.Errorf("published: %w", err)
+		}
+	} else {
+		e.Published = e.Updated
+	}
+
+	return e, nil
+}
+
+// joinAuthors добавляет имя автора к списку авторов, разделенному запятыми.
+func joinAuthors(authors string, name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return authors
+	}
+	if authors == "" {
+		return name
+	}
+	return authors + ", " + name
+}
+
+// parseAtomTime разбирает дату в формате RFC 3339, используемом в Atom.
+func parseAtomTime(s string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// isAtomName проверяет, что элемент принадлежит пространству имен Atom.
+// Элементы без пространства имен также считаются элементами Atom.
+func isAtomName(n xml.Name) bool {
+	return n.Space == "" || n.Space == atomNS
+}
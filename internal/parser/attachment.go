@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// documentExts расширения файлов, ссылки на которые в тексте записи
+// считаются прикрепленными документами (например, тексты актов в pdf).
+var documentExts = map[string]bool{
+	".pdf":  true,
+	".doc":  true,
+	".docx": true,
+	".rtf":  true,
+	".odt":  true,
+	".xls":  true,
+	".xlsx": true,
+	".zip":  true,
+}
+
+// addAttachment добавляет вложение в запись. Относительный адрес вложения
+// разрешается относительно адреса записи, повторные вложения пропускаются.
+// Если вид или MIME тип вложения не заданы, они определяются по расширению файла.
+func addAttachment(e *feed.Entry, a feed.Attachment) {
+	a.Url = resolveURL(e.Url, strings.TrimSpace(a.Url))
+	if a.Url == "" {
+		return
+	}
+	for _, at := range e.Attachments {
+		if at.Url == a.Url {
+			return
+		}
+	}
+
+	if a.MimeType == "" {
+		a.MimeType = mime.TypeByExtension(strings.ToLower(path.Ext(urlPath(a.Url))))
+		// Параметры типа (charset) для вложений не нужны
+		if i := strings.Index(a.MimeType, ";"); i >= 0 {
+			a.MimeType = a.MimeType[:i]
+		}
+	}
+	if a.Kind == "" {
+		a.Kind = attachmentKind(a.MimeType, a.Url)
+	}
+	a.Caption = strings.Join(strings.Fields(a.Caption), " ")
+
+	e.Attachments = append(e.Attachments, a)
+}
+
+// extractAttachments находит в html фрагменте встроенные изображения, видео,
+// аудио и ссылки на документы и добавляет их во вложения записи.
+func extractAttachments(e *feed.Entry, fragment string) {
+	if fragment == "" {
+		return
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		return
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Img:
+				caption := getRequiredDataAttr("alt", n)
+				if caption == "" {
+					caption = getRequiredDataAttr("title", n)
+				}
+				addAttachment(e, feed.Attachment{
+					Url:     getRequiredDataAttr("src", n),
+					Caption: caption,
+					Kind:    feed.AttachmentPhoto,
+				})
+			case atom.Video, atom.Audio, atom.Source:
+				kind := feed.AttachmentVideo
+				if n.DataAtom == atom.Audio || (n.Parent != nil && n.Parent.DataAtom == atom.Audio) {
+					kind = feed.AttachmentAudio
+				}
+				if src := getRequiredDataAttr("src", n); src != "" {
+					addAttachment(e, feed.Attachment{
+						Url:      src,
+						MimeType: getRequiredDataAttr("type", n),
+						Caption:  getRequiredDataAttr("title", n),
+						Kind:     kind,
+					})
+				}
+			case atom.A:
+				href := getRequiredDataAttr("href", n)
+				if documentExts[strings.ToLower(path.Ext(urlPath(href)))] {
+					addAttachment(e, feed.Attachment{
+						Url:     href,
+						Caption: textContent(renderInner(n)),
+						Kind:    feed.AttachmentDocument,
+					})
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	for _, n := range nodes {
+		f(n)
+	}
+}
+
+// attachmentKind определяет вид вложения по MIME типу или расширению файла.
+func attachmentKind(mimeType string, rawURL string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return feed.AttachmentPhoto
+	case strings.HasPrefix(mimeType, "video/"):
+		return feed.AttachmentVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return feed.AttachmentAudio
+	case documentExts[strings.ToLower(path.Ext(urlPath(rawURL)))]:
+		return feed.AttachmentDocument
+	}
+	return feed.AttachmentOther
+}
+
+// parseLength разбирает размер вложения из атрибута length, при ошибке возвращает 0.
+func parseLength(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// resolveURL разрешает ссылку ref относительно адреса base.
+func resolveURL(base string, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return r.String()
+	}
+	return b.ResolveReference(r).String()
+}
+
+// urlPath возвращает путь адреса без параметров запроса.
+func urlPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Path
+}
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"log/slog"
+
+	"github.com/terratensor/kremlin-parser/internal/httpcache"
+	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
+)
+
+// cachedValidators возвращает сохраненные валидаторы страницы url.
+func (p *Parser) cachedValidators(url string) (v httpcache.Validators, ok bool) {
+	if p.Cache == nil {
+		return v, false
+	}
+	return p.Cache.Get(url)
+}
+
+// cacheValidators запоминает валидаторы обработанной страницы url
+// вместе с адресом следующей страницы.
+func (p *Parser) cacheValidators(url string, doc *document) {
+	if p.Cache == nil {
+		return
+	}
+	v := doc.Validators
+	v.Next = p.Meta.Next
+	p.Cache.Set(url, v)
+}
+
+// flushCache записывает валидаторы на диск.
+func (p *Parser) flushCache(log *slog.Logger) {
+	if p.Cache == nil {
+		return
+	}
+	if err := p.Cache.Flush(); err != nil {
+		log.Error("failed to save http cache", sl.Err(err))
+	}
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"log/slog"
+
+	"github.com/terratensor/kremlin-parser/internal/config"
+	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
+)
+
+// resume восстанавливает позицию обхода ленты из контрольной точки.
+//
+// Возвращает номер страницы, с которой продолжается обход, и false,
+// если лента уже пройдена до конца или до page_count и парсить нечего.
+// Если контрольной точки нет, обход начинается с первой страницы.
+func (p *Parser) resume(log *slog.Logger) (int, bool) {
+	p.progress.StartURL = p.URI
+	if !p.Resume || p.Checkpoints == nil {
+		return 1, true
+	}
+
+	cp, ok := p.Checkpoints.Get(p.URI)
+	if !ok {
+		log.Info("checkpoint not found, start from the first page")
+		return 1, true
+	}
+	if cp.Done() {
+		log.Info("feed already parsed to the end", slog.Int("pages", cp.Pages), slog.String("self", cp.Self))
+		return 0, false
+	}
+	// Страницы считаются от начала ленты, поэтому если до контрольной точки
+	// пройдено page_count страниц, загружать следующую не нужно
+	if p.Strategy != config.StrategyIncremental && p.Since == nil && cp.Pages >= p.PageCount {
+		log.Info("page count already reached", slog.Int("pages", cp.Pages), slog.Int("page_count", p.PageCount))
+		return 0, false
+	}
+
+	p.progress = cp
+	p.Meta.Self = cp.Self
+	p.Meta.Next = cp.Next
+
+	log.Info(
+		"resume from checkpoint",
+		slog.String("next", cp.Next),
+		slog.Int("pages", cp.Pages),
+		slog.Int("inserted", cp.Inserted),
+		slog.Int("updated", cp.Updated),
+	)
+
+	return cp.Pages + 1, true
+}
+
+// saveCheckpoint записывает контрольную точку после успешной обработки страницы url.
+func (p *Parser) saveCheckpoint(log *slog.Logger, url string, page int) {
+	if !p.Resume || p.Checkpoints == nil {
+		return
+	}
+
+	p.progress.Self = url
+	p.progress.Next = p.Meta.Next
+	p.progress.Pages = page
+
+	if err := p.Checkpoints.Save(p.progress); err != nil {
+		log.Error("failed to save checkpoint", slog.String("url", url), sl.Err(err))
+	}
+}
+
+// countStatus учитывает результат сохранения записи в контрольной точке.
+func (p *Parser) countStatus(status entryStatus) {
+	switch status {
+	case entryInserted:
+		p.progress.Inserted++
+	case entryUpdated:
+		p.progress.Updated++
+	case entryUnchanged:
+		p.progress.Unchanged++
+	case entryFailed:
+		p.progress.Failed++
+	}
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
+	"github.com/terratensor/kremlin-parser/internal/storage"
+)
+
+// findCounterpart ищет среди найденных в хранилище записей stored версию
+// записи на другом языке и, если она найдена, сохраняет её идентификатор в e.CounterpartID.
+func (p *Parser) findCounterpart(e *feed.Entry, stored map[string]*feed.Entry) *feed.Entry {
+	if e.CounterpartUrl == "" {
+		return nil
+	}
+
+	cp := stored[e.CounterpartUrl]
+	if cp == nil {
+		return nil
+	}
+
+	e.CounterpartID = cp.ID
+	return cp
+}
+
+// linkCounterpart записывает в версию записи на другом языке cp ссылку на
+// сохраненную запись e, чтобы связь между языковыми версиями была двусторонней.
+// Возвращает cp, если её нужно сохранить, или nil, если ссылка уже есть.
+func linkCounterpart(e *feed.Entry, cp *feed.Entry) *feed.Entry {
+	if cp == nil || e.ID == nil {
+		return nil
+	}
+	if cp.CounterpartID != nil && *cp.CounterpartID == *e.ID {
+		return nil
+	}
+
+	cp.CounterpartID = e.ID
+	cp.CounterpartUrl = e.Url
+	return cp
+}
+
+// saveCounterparts записывает обратные ссылки на новые записи страницы entries
+// в их версии на другом языке cps одним вызовом Bulk. Используется, если
+// идентификаторы новых записей стали известны только после их сохранения.
+func (p *Parser) saveCounterparts(ctx context.Context, log *slog.Logger, entries []*feed.Entry, statuses []entryStatus, cps []*feed.Entry) {
+	byURL := make(map[string]*feed.Entry, len(cps))
+	for _, cp := range cps {
+		byURL[cp.Url] = cp
+	}
+
+	var links []feed.Entry
+	for i, e := range entries {
+		if statuses[i] != entryInserted || e.CounterpartUrl == "" {
+			continue
+		}
+		if link := linkCounterpart(e, byURL[e.CounterpartUrl]); link != nil {
+			links = append(links, *link)
+		}
+	}
+	if len(links) == 0 {
+		return
+	}
+
+	var bulkErr *storage.BulkError
+	if err := p.entries.Storage.Bulk(ctx, &links); err != nil && !errors.As(err, &bulkErr) {
+		log.Error("failed link counterparts", slog.Int("entries", len(links)), sl.Err(err))
+		return
+	}
+
+	for i := range links {
+		var err error
+		if bulkErr != nil {
+			err = bulkErr.Items[i]
+		}
+		logLink(log, &links[i], err)
+	}
+}
+
+// logLink логирует результат сохранения обратной ссылки в версии записи на другом языке cp.
+func logLink(log *slog.Logger, cp *feed.Entry, err error) {
+	if err != nil {
+		log.Error("failed link counterpart", slog.String("url", cp.Url), sl.Err(err))
+		return
+	}
+	log.Debug(
+		"counterpart linked",
+		slog.String("url", cp.CounterpartUrl),
+		slog.String("counterpart_url", cp.Url),
+	)
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/httpcache"
+	"golang.org/x/net/html/charset"
+)
+
+// Format формат ленты.
+type Format string
+
+const (
+	FormatAtom Format = "atom"
+	FormatRSS  Format = "rss" // RSS 2.0 и совместимые с ним RSS 0.9x
+	FormatRDF  Format = "rdf" // RSS 1.0
+)
+
+// ErrUnknownFormat возвращается, если корневой элемент документа
+// не соответствует ни одному из поддерживаемых форматов ленты.
+var ErrUnknownFormat = errors.New("unknown feed format")
+
+// document результат декодирования одной страницы ленты.
+type document struct {
+	Format  Format
+	Meta    Meta
+	Entries []feed.Entry
+	// Author автор ленты, используется для записей без собственного автора
+	Author string
+	// Validators валидаторы ответа сервера для условного запроса страницы.
+	// NotModified означает, что сервер ответил 304 и страница не изменилась,
+	// в этом случае в Meta известны только адреса текущей и следующей страниц
+	Validators  httpcache.Validators
+	NotModified bool
+	// Errors ошибки отдельных элементов, которые были пропущены при разборе
+	Errors []error
+}
+
+// DecodeError ошибка декодирования отдельного элемента ленты.
+type DecodeError struct {
+	Element string // имя элемента, например entry
+	Index   int    // порядковый номер элемента на странице, начиная с 1
+	ID      string // идентификатор элемента, если удалось его получить
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Index == 0 {
+		return fmt.Sprintf("%s: %v", e.Element, e.Err)
+	}
+	if e.ID == "" {
+		return fmt.Sprintf("%s #%d: %v", e.Element, e.Index, e.Err)
+	}
+	return fmt.Sprintf("%s #%d (%s): %v", e.Element, e.Index, e.ID, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeFeed определяет формат ленты по корневому элементу и декодирует её.
+func decodeFeed(data []byte) (*document, error) {
+	format, err := detectFormat(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatAtom:
+		return decodeAtom(bytes.NewReader(data))
+	default:
+		return decodeRSS(bytes.NewReader(data))
+	}
+}
+
+// detectFormat возвращает формат ленты по имени корневого элемента документа.
+func detectFormat(data []byte) (Format, error) {
+	const op = "parser.detectFormat"
+
+	d := newXMLDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "feed":
+			return FormatAtom, nil
+		case "rss":
+			return FormatRSS, nil
+		case "RDF":
+			return FormatRDF, nil
+		}
+		return "", fmt.Errorf("%s: %w: <%s>", op, ErrUnknownFormat, start.Name.Local)
+	}
+}
+
+// newXMLDecoder создает нестрогий декодер XML, понимающий кодировки,
+// отличные от UTF-8 (например, windows-1251 в лентах госсайтов).
+func newXMLDecoder(r io.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.CharsetReader = charset.NewReaderLabel
+	return d
+}
@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectFormat(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data string
+		want Format
+	}{
+		{"atom", `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"></feed>`, FormatAtom},
+		{"rss", `<?xml version="1.0"?><!-- comment --><rss version="2.0"><channel/></rss>`, FormatRSS},
+		{"rdf", `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/"></rdf:RDF>`, FormatRDF},
+	} {
+		got, err := detectFormat([]byte(c.data))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	if _, err := detectFormat([]byte(`<html><body>not a feed</body></html>`)); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("html: got error %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestDecodeAtom(t *testing.T) {
+	const data = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>http://kremlin.ru/events/president/news/feed</id>
+  <updated>2024-01-10T12:00:00+04:00</updated>
+  <author><name>Президент России</name></author>
+  <link rel="self" href="http://kremlin.ru/events/president/news/feed/page/1"/>
+  <link rel="next" href="http://kremlin.ru/events/president/news/feed/page/2"/>
+  <entry>
+    <id>http://kremlin.ru/events/president/news/73001</id>
+    <title>Встреча &amp; беседа</title>
+    <updated>2024-01-10T12:00:00+04:00</updated>
+    <published>2024-01-10T10:00:00+04:00</published>
+    <summary type="html">&lt;p&gt;Краткое &lt;b&gt;содержание&lt;/b&gt;&lt;/p&gt;</summary>
+    <content type="html"><![CDATA[<p>Полный <i>текст</i></p>]]></content>
+    <category term="http://kremlin.ru/catalog/persons/1" label="Путин Владимир"/>
+    <link rel="enclosure" href="http://static.kremlin.ru/media/1.jpg" type="image/jpeg" length="1024" title="Фото"/>
+  </entry>
+  <entry>
+    <id>tag:kremlin.ru,2024:73002</id>
+    <link rel="alternate" href="http://kremlin.ru/events/president/news/73002"/>
+    <title type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml">Заголовок</div></title>
+    <updated>2024-01-09T12:00:00Z</updated>
+  </entry>
+  <entry>
+    <id>http://kremlin.ru/events/president/news/73003</id>
+    <title>Битая дата</title>
+    <updated>вчера</updated>
+  </entry>
+</feed>`
+
+	doc, err := decodeAtom(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Format != FormatAtom {
+		t.Errorf("format: got %q, want %q", doc.Format, FormatAtom)
+	}
+	if doc.Meta.Next != "http://kremlin.ru/events/president/news/feed/page/2" {
+		t.Errorf("next: got %q", doc.Meta.Next)
+	}
+	if doc.Author != "Президент России" {
+		t.Errorf("author: got %q", doc.Author)
+	}
+	if len(doc.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(doc.Entries))
+	}
+	if len(doc.Errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(doc.Errors), doc.Errors)
+	}
+	var decodeErr *DecodeError
+	if !errors.As(doc.Errors[0], &decodeErr) || decodeErr.Index != 3 {
+		t.Errorf("error: got %v, want entry #3", doc.Errors[0])
+	}
+
+	e := doc.Entries[0]
+	if e.Title != "Встреча & беседа" {
+		t.Errorf("title: got %q", e.Title)
+	}
+	if e.Summary != "<p>Краткое <b>содержание</b></p>" {
+		t.Errorf("escaped html summary: got %q", e.Summary)
+	}
+	if e.Content != "<p>Полный <i>текст</i></p>" {
+		t.Errorf("cdata content: got %q", e.Content)
+	}
+	published := time.Date(2024, 1, 10, 6, 0, 0, 0, time.UTC)
+	if e.Published == nil || !e.Published.Equal(published) {
+		t.Errorf("published: got %v, want %v", e.Published, published)
+	}
+	if len(e.Persons) != 1 || e.Persons[0] != "Путин Владимир" {
+		t.Errorf("persons: got %v", e.Persons)
+	}
+	if len(e.Attachments) != 1 || e.Attachments[0].Size != 1024 || e.Attachments[0].Caption != "Фото" {
+		t.Errorf("attachments: got %+v", e.Attachments)
+	}
+
+	e = doc.Entries[1]
+	if e.Url != "http://kremlin.ru/events/president/news/73002" {
+		t.Errorf("url from alternate link: got %q", e.Url)
+	}
+	if !strings.Contains(e.Title, "Заголовок") {
+		t.Errorf("xhtml title: got %q", e.Title)
+	}
+	if e.Published == nil || !e.Published.Equal(*e.Updated) {
+		t.Errorf("published without element: got %v, want %v", e.Published, e.Updated)
+	}
+}
+
+func TestDecodeRSS(t *testing.T) {
+	const data = `<?xml version="1.0" encoding="utf-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title>Новости</title>
+    <link>http://kremlin.ru/events/president/news</link>
+    <atom:link rel="next" href="http://kremlin.ru/events/president/news/feed/page/2"/>
+    <pubDate>Wed, 10 Jan 2024 09:00:00 +0300</pubDate>
+    <lastBuildDate>Wed, 10 Jan 2024 12:00:00 MSK</lastBuildDate>
+    <item>
+      <title><![CDATA[Указ № 15 «О мерах»]]></title>
+      <link>http://kremlin.ru/acts/news/73001</link>
+      <guid isPermaLink="false">73001</guid>
+      <pubDate>Wed, 10 Jan 2024 10:00:00 GMT</pubDate>
+      <dc:creator>Пресс-служба</dc:creator>
+      <author>press@kremlin.ru</author>
+      <category domain="http://kremlin.ru/catalog/keywords">Экономика</category>
+      <enclosure url="http://static.kremlin.ru/media/1.pdf" type="application/pdf" length="2048"/>
+      <description>&lt;p&gt;Анонс&lt;/p&gt;</description>
+      <content:encoded><![CDATA[<p>Полный текст</p>]]></content:encoded>
+    </item>
+    <item>
+      <title>Без полного текста</title>
+      <guid>http://kremlin.ru/events/president/news/73002</guid>
+      <pubDate>Tue, 9 Jan 2024 18:30:00 +0300</pubDate>
+      <description><![CDATA[<p>Только <b>описание</b></p>]]></description>
+    </item>
+    <item>
+      <title>Без даты</title>
+      <link>http://kremlin.ru/events/president/news/73003</link>
+    </item>
+  </channel>
+</rss>`
+
+	doc, err := decodeRSS(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Format != FormatRSS {
+		t.Errorf("format: got %q, want %q", doc.Format, FormatRSS)
+	}
+	if doc.Meta.ID != "http://kremlin.ru/events/president/news" || doc.Meta.Next != "http://kremlin.ru/events/president/news/feed/page/2" {
+		t.Errorf("meta: got %+v", doc.Meta)
+	}
+	updated := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	if doc.Meta.Updated == nil || doc.Meta.Updated.Format(time.TimeOnly) != updated.Format(time.TimeOnly) {
+		t.Errorf("lastBuildDate: got %v", doc.Meta.Updated)
+	}
+	if len(doc.Entries) != 2 || len(doc.Errors) != 1 {
+		t.Fatalf("got %d entries and %d errors, want 2 and 1", len(doc.Entries), len(doc.Errors))
+	}
+
+	e := doc.Entries[0]
+	if e.Title != "Указ № 15 «О мерах»" {
+		t.Errorf("cdata title: got %q", e.Title)
+	}
+	if e.Url != "http://kremlin.ru/acts/news/73001" {
+		t.Errorf("url: got %q", e.Url)
+	}
+	if e.Content != "<p>Полный текст</p>" || e.Summary != "<p>Анонс</p>" {
+		t.Errorf("content and summary: got %q and %q", e.Content, e.Summary)
+	}
+	published := time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC)
+	if e.Published == nil || !e.Published.Equal(published) {
+		t.Errorf("RFC1123 pubDate: got %v, want %v", e.Published, published)
+	}
+	if e.Author != "Пресс-служба" {
+		t.Errorf("author: got %q", e.Author)
+	}
+	if len(e.Attachments) != 1 || e.Attachments[0].Size != 2048 {
+		t.Errorf("attachments: got %+v", e.Attachments)
+	}
+
+	e = doc.Entries[1]
+	if e.Url != "http://kremlin.ru/events/president/news/73002" {
+		t.Errorf("url from permalink guid: got %q", e.Url)
+	}
+	if e.Content != "<p>Только <b>описание</b></p>" || e.Summary != "" {
+		t.Errorf("description as content: got %q and %q", e.Content, e.Summary)
+	}
+	published = time.Date(2024, 1, 9, 15, 30, 0, 0, time.UTC)
+	if e.Published == nil || !e.Published.Equal(published) {
+		t.Errorf("RFC1123Z pubDate: got %v, want %v", e.Published, published)
+	}
+}
+
+func TestDecodeRDF(t *testing.T) {
+	const data = `<?xml version="1.0" encoding="utf-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="http://kremlin.ru/events/president/news">
+    <title>Новости</title>
+    <link>http://kremlin.ru/events/president/news</link>
+    <dc:date>2024-01-10T12:00:00+03:00</dc:date>
+  </channel>
+  <item rdf:about="http://kremlin.ru/events/president/news/73001">
+    <title>Запись RDF</title>
+    <description>Текст &amp;laquo;записи&amp;raquo;</description>
+    <dc:date>2024-01-10</dc:date>
+    <dc:subject>Экономика</dc:subject>
+  </item>
+</rdf:RDF>`
+
+	doc, err := decodeFeed([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Format != FormatRDF {
+		t.Errorf("format: got %q, want %q", doc.Format, FormatRDF)
+	}
+	if doc.Meta.Updated == nil {
+		t.Error("channel dc:date is not decoded")
+	}
+	if len(doc.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1: %v", len(doc.Entries), doc.Errors)
+	}
+
+	e := doc.Entries[0]
+	if e.Url != "http://kremlin.ru/events/president/news/73001" {
+		t.Errorf("url from rdf:about: got %q", e.Url)
+	}
+	if e.Content != "Текст &laquo;записи&raquo;" {
+		t.Errorf("content: got %q", e.Content)
+	}
+	published := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	if e.Published == nil || !e.Published.Equal(published) {
+		t.Errorf("dc:date: got %v, want %v", e.Published, published)
+	}
+	if len(e.Categories) != 1 || e.Categories[0] != "Экономика" {
+		t.Errorf("categories from dc:subject: got %v", e.Categories)
+	}
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
+)
+
+// keepDownloads переносит в запись e сведения о загруженных файлах из ранее
+// сохраненной версии dbe, чтобы обновление записи не теряло уже загруженные
+// вложения и не загружало их повторно.
+func keepDownloads(e, dbe *feed.Entry) {
+	for i := range e.Attachments {
+		a := &e.Attachments[i]
+		if a.Path != "" {
+			continue
+		}
+		for _, old := range dbe.Attachments {
+			if old.Url == a.Url && old.Path != "" {
+				a.Digest, a.Path = old.Digest, old.Path
+				break
+			}
+		}
+	}
+}
+
+// attachmentsDir каталог внутри OutputPath, в который сохраняются вложения.
+const attachmentsDir = "attachments"
+
+// downloadAttachments загружает вложения записи, если включена опция download_attachments.
+//
+// Файлы сохраняются в каталог OutputPath/attachments по адресу содержимого:
+// attachments/ab/cd/abcd...ef.pdf, где имя файла — sha256 его содержимого.
+// Одинаковые файлы разных записей хранятся в одном экземпляре.
+// Вложения с заполненным Path уже загружены и пропускаются.
+// Ошибки загрузки логируются и не прерывают парсинг.
+func (p *Parser) downloadAttachments(ctx context.Context, log *slog.Logger, e *feed.Entry) {
+	if !p.DownloadAttachments {
+		return
+	}
+
+	for i := range e.Attachments {
+		a := &e.Attachments[i]
+		if a.Path != "" {
+			continue
+		}
+
+		if err := p.wait(ctx, log, a.Url); errors.Is(err, ErrDisallowed) {
+			continue
+		} else if err != nil {
+			return
+		}
+
+		if err := p.downloadAttachment(ctx, a); err != nil {
+			log.Error("failed to download attachment", slog.String("url", a.Url), sl.Err(err))
+			continue
+		}
+		log.Debug("attachment downloaded", slog.String("url", a.Url), slog.String("path", a.Path))
+	}
+}
+
+// downloadAttachment загружает вложение во временный файл, одновременно вычисляя
+// его sha256, и переносит файл на постоянное место. Заполняет Digest, Path и Size.
+func (p *Parser) downloadAttachment(ctx context.Context, a *feed.Attachment) error {
+	const op = "parser.downloadAttachment"
+
+	resp, err := p.Fetcher.Fetch(ctx, a.Url, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status code error: %d %s", op, resp.StatusCode, resp.Status)
+	}
+
+	dir := filepath.Join(p.OutputPath, attachmentsDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	rel := filepath.Join(attachmentsDir, digest[:2], digest[2:4], digest+strings.ToLower(path.Ext(urlPath(a.Url))))
+	dst := filepath.Join(p.OutputPath, rel)
+
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := os.Rename(tmp.Name(), dst); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	a.Digest = "sha256:" + digest
+	a.Path = filepath.ToSlash(rel)
+	a.Size = size
+	if a.MimeType == "" {
+		a.MimeType = resp.Header.Get("Content-Type")
+	}
+
+	return nil
+}
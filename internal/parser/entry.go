@@ -1,70 +1,61 @@
 package parser
 
 import (
-	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
 	"github.com/terratensor/kremlin-parser/internal/entities/feed"
-	"golang.org/x/net/html"
-	"time"
 )
 
-func (p *Parser) parseEntries(n *html.Node) []feed.Entry {
-
-	var entries []feed.Entry
-	var f func(*html.Node)
-
-	e := feed.Entry{}
-
-	f = func(n *html.Node) {
-
-		if n.Type == html.ElementNode && n.Data == "entry" {
-			for cl := n.FirstChild; cl != nil; cl = cl.NextSibling {
-
-				if cl.Type == html.ElementNode && cl.Data == "title" {
-					e.Title = getInnerText(cl)
-				}
-
-				if cl.Type == html.ElementNode && cl.Data == "id" {
-					e.Url = getInnerText(cl)
-				}
-
-				if cl.Type == html.ElementNode && cl.Data == "updated" {
-
-					t, err := time.Parse("2006-01-02T15:04:05-07:00", getInnerText(cl))
-					if err != nil {
-						fmt.Println(err)
-						return
-					}
-					e.Updated = &t
-				}
-
-				if cl.Type == html.ElementNode && cl.Data == "published" {
-					t, err := time.Parse("2006-01-02T15:04:05-07:00", getInnerText(cl))
-					if err != nil {
-						fmt.Println(err)
-						return
-					}
-					e.Published = &t
-				}
-
-				if cl.Type == html.ElementNode && cl.Data == "summary" {
-					e.Summary = getInnerText(cl)
-				}
-
-				if cl.Type == html.ElementNode && cl.Data == "content" {
-					e.Content = getInnerText(cl)
-				}
-
-			}
-
-			e.Language = p.Lang
-			e.ResourceID = p.ResourceID
-			entries = append(entries, e) //fmt.Println(entry)
+// numberRe находит номер акта в заголовке или тексте записи:
+// «Указ № 123», «Федеральный закон № 45-ФЗ», «Executive Order No. 123».
+// Между знаком номера и самим номером может стоять неразрывный пробел,
+// в том числе в виде html-сущности.
+var numberRe = regexp.MustCompile(`(?:№|\bNo\.)(?:[\s\x{00a0}]|&nbsp;|&#160;)*([0-9](?:[0-9A-Za-zА-Яа-яЁё\-/.]*[0-9A-Za-zА-Яа-яЁё])?|[A-Za-zА-Яа-яЁё]{1,4}-[0-9][0-9A-Za-zА-Яа-яЁё\-/]*)`)
+
+// parseEntries возвращает записи декодированной страницы ленты,
+// дополненные языком и идентификатором ресурса парсера.
+func (p *Parser) parseEntries(doc *document) []feed.Entry {
+	entries := make([]feed.Entry, 0, len(doc.Entries))
+
+	for _, e := range doc.Entries {
+		e.Language = p.Lang
+		e.ResourceID = p.ResourceID
+		if e.Author == "" {
+			e.Author = doc.Author
 		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
+		e.EventKey = feed.EventKey(e.Url)
+		e.CounterpartUrl = feed.CounterpartURL(e.Url)
+		extractAttachments(&e, e.Content)
+		if isActURL(e.Url) {
+			e.Number = extractNumber(e.Title, e.Content)
 		}
+		entries = append(entries, e)
 	}
-	f(n)
 
 	return entries
 }
+
+// isActURL проверяет, что запись относится к разделу «Документы» (/acts/) сайта kremlin.ru.
+func isActURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	if host != "kremlin.ru" && !strings.HasSuffix(host, ".kremlin.ru") {
+		return false
+	}
+	return strings.HasPrefix(u.Path, "/acts/")
+}
+
+// extractNumber возвращает первый найденный номер акта, просматривая тексты по порядку.
+func extractNumber(texts ...string) string {
+	for _, text := range texts {
+		if m := numberRe.FindStringSubmatch(text); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
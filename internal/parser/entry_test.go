@@ -0,0 +1,44 @@
+package parser
+
+import "testing"
+
+func TestExtractNumber(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		want string
+	}{
+		{"Указ № 123 «О мерах»", "123"},
+		{"Федеральный закон №45-ФЗ", "45-ФЗ"},
+		{"Распоряжение №&nbsp;Пр-1234", "Пр-1234"},
+		{"Указ № 570", "570"},
+		{"Постановление № 12/3 от 1 января", "12/3"},
+		{"Executive Order No. 808", "808"},
+		{"Встреча с губернатором", ""},
+		{"Nobody No.body", ""},
+	} {
+		if got := extractNumber(c.text); got != c.want {
+			t.Errorf("extractNumber(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+
+	if got := extractNumber("Без номера", "<p>Указ № 7</p>"); got != "7" {
+		t.Errorf("number from content: got %q, want %q", got, "7")
+	}
+}
+
+func TestIsActURL(t *testing.T) {
+	for _, c := range []struct {
+		url  string
+		want bool
+	}{
+		{"http://kremlin.ru/acts/news/73001", true},
+		{"http://www.kremlin.ru/acts/bank/123", true},
+		{"http://en.kremlin.ru/acts/news/73001", true},
+		{"http://kremlin.ru/events/president/news/73001", false},
+		{"http://example.com/acts/news/1", false},
+	} {
+		if got := isActURL(c.url); got != c.want {
+			t.Errorf("isActURL(%q) = %v, want %v", c.url, got, c.want)
+		}
+	}
+}
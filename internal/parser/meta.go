@@ -1,8 +1,6 @@
 package parser
 
 import (
-	"fmt"
-	"golang.org/x/net/html"
 	"time"
 )
 
@@ -28,55 +26,24 @@ func NewMeta() *Meta {
 	return &meta
 }
 
-// parseMeta парсит данный html.Node для извлечения мета-информации.
-//
-// Принимает указатель на html.Node в качестве параметра и возвращает указатель на структуру Meta.
-func (p *Parser) parseMeta(node *html.Node) {
-	var f func(*html.Node)
-
-	meta := Meta{}
-
-	f = func(node *html.Node) {
-
-		// Если у ноды есть родитель и этот родитель — тэг feed
-		if node.Parent != nil && node.Parent.Data == "feed" {
-
-			if node.Type == html.ElementNode && node.Data == "updated" {
-				t, err := time.Parse("2006-01-02T15:04:05-07:00", getInnerText(node))
-				if err != nil {
-					fmt.Println(err)
-					return
-				}
-				meta.Updated = &t
-			}
-			if node.Type == html.ElementNode && node.Data == "id" {
-				meta.ID = getInnerText(node)
-			}
-			//
-			if node.Type == html.ElementNode && node.Data == "link" {
-
-				if nodeHasRequiredRelAttr("self", node) {
-					meta.Self = getRequiredDataAttr("href", node)
-				}
-				if nodeHasRequiredRelAttr("prev", node) {
-					meta.Prev = getRequiredDataAttr("href", node)
-				}
-				if nodeHasRequiredRelAttr("first", node) {
-					meta.First = getRequiredDataAttr("href", node)
-				}
-				if nodeHasRequiredRelAttr("next", node) {
-					meta.Next = getRequiredDataAttr("href", node)
-				}
-				if nodeHasRequiredRelAttr("last", node) {
-					meta.Last = getRequiredDataAttr("href", node)
-				}
-			}
-		}
-		for c := node.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
-		}
+// setLink заполняет навигационную ссылку Meta в соответствии со значением атрибута rel.
+func (m *Meta) setLink(rel, href string) {
+	switch rel {
+	case "self":
+		m.Self = href
+	case "prev", "previous":
+		m.Prev = href
+	case "first":
+		m.First = href
+	case "next":
+		m.Next = href
+	case "last":
+		m.Last = href
 	}
-	f(node)
+}
 
+// parseMeta сохраняет навигационную информацию декодированной страницы ленты в Parser.
+func (p *Parser) parseMeta(doc *document) {
+	meta := doc.Meta
 	p.Meta = &meta
 }
@@ -3,18 +3,26 @@ package parser
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/gosimple/slug"
+	"github.com/terratensor/kremlin-parser/internal/checkpoint"
 	"github.com/terratensor/kremlin-parser/internal/config"
 	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/fetcher"
+	"github.com/terratensor/kremlin-parser/internal/httpcache"
+	"github.com/terratensor/kremlin-parser/internal/lib/atomicfile"
 	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
-	"golang.org/x/net/html"
+	"github.com/terratensor/kremlin-parser/internal/lib/ratelimit"
+	"github.com/terratensor/kremlin-parser/internal/robots"
+	"io"
 	"log"
 	"log/slog"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -25,31 +33,79 @@ type Parser struct {
 	ResourceID     int
 	Lang           string
 	URI            string
-	PageCount      int
-	OutputPath     string
-	Delay          *time.Duration
-	Meta           *Meta
-	entries        *feed.Entries
+	PageURL        string
+	FetchArticles  bool
+	// DownloadAttachments включает загрузку вложений записей в OutputPath
+	DownloadAttachments bool
+	PageCount           int
+	Strategy            string
+	StopAfterKnown      int
+	// Since и Until границы периода публикации записей [Since, Until)
+	Since      *time.Time
+	Until      *time.Time
+	OutputPath string
+	Delay      *time.Duration
+	// Limiter выдерживает паузу между запросами к одному хосту,
+	// для соблюдения паузы при параллельном парсинге лент должен быть общим
+	Limiter *ratelimit.Limiter
+	// Fetcher выполняет все HTTP запросы парсера, по умолчанию fetcher.Client
+	// с настройками из секции http конфига
+	Fetcher fetcher.Fetcher
+	// Pages загружает страницы ленты, если nil, используется Fetcher.
+	// Отдельный загрузчик позволяет записывать в архив только страницы ленты
+	Pages fetcher.Fetcher
+	// Robots правила robots.txt хостов, если nil, robots.txt не учитывается
+	Robots *robots.Checker
+	// Retry политика повторных запросов страниц ленты, failures — количество
+	// неудачных запросов за текущий обход, ограничено Retry.ErrorBudget
+	Retry    config.Retry
+	failures int
+	// Cache валидаторы ETag и Last-Modified страниц ленты для условных запросов
+	Cache *httpcache.Store
+	Meta  *Meta
+	// Checkpoints хранилище контрольных точек, при Resume позиция обхода
+	// восстанавливается из него и сохраняется после каждой страницы
+	Checkpoints *checkpoint.Store
+	Resume      bool
+	// Force обновлять сохраненные записи, даже если время их обновления не изменилось,
+	// используется при повторном разборе архива после исправления парсера
+	Force    bool
+	progress checkpoint.Checkpoint
+	entries  *feed.Entries
 }
 
 func New(uri config.StartURL, cfg *config.Config, entries *feed.Entries) Parser {
+	// Период проверяется при загрузке конфига
+	since, until, _ := cfg.Parser.Period()
+
 	parser := Parser{
-		ID:             uuid.New(),
-		ManticoreIndex: cfg.ManticoreIndex,
-		SaveToFile:     cfg.SaveToFile,
-		ResourceID:     cfg.Parser.ResourceID,
-		Lang:           uri.Lang,
-		URI:            uri.Url,
-		PageCount:      cfg.Parser.PageCount,
-		OutputPath:     cfg.Parser.OutputPath,
-		Delay:          cfg.ParseDelay,
-		Meta:           NewMeta(),
-		entries:        entries,
+		ID:                  uuid.New(),
+		ManticoreIndex:      cfg.ManticoreIndex,
+		SaveToFile:          cfg.SaveToFile,
+		ResourceID:          cfg.Parser.ResourceID,
+		Lang:                uri.Lang,
+		URI:                 uri.Url,
+		PageURL:             uri.PageURL,
+		FetchArticles:       uri.FetchArticles,
+		DownloadAttachments: cfg.Parser.DownloadAttachments,
+		PageCount:           cfg.Parser.PageCount,
+		Strategy:            cfg.Parser.Strategy,
+		StopAfterKnown:      cfg.Parser.StopAfterKnown,
+		Since:               since,
+		Until:               until,
+		OutputPath:          cfg.Parser.OutputPath,
+		Delay:               cfg.ParseDelay,
+		Limiter:             ratelimit.New(*cfg.ParseDelay),
+		Retry:               cfg.Parser.Retry,
+		Meta:                NewMeta(),
+		entries:             entries,
 	}
 	return parser
 }
 
 // Parse парсит указанное количество страниц rss ленты сайта кремля.
+// В инкрементальном режиме (strategy: incremental) парсит страницы, пока
+// на них встречаются новые или измененные записи.
 // Сохраняет каждую страницу в отдельный json файл.
 // При каждом успешном парсинге возвращает ссылку на следующую страницу rss ленты.
 // Делает установленную в конфиге паузу между парсингами (5 сек по умолчанию).
@@ -62,13 +118,33 @@ func (p *Parser) Parse(ctx context.Context, log *slog.Logger) {
 		slog.String("lang", p.Lang),
 	)
 
-	count := 1
+	if p.Fetcher == nil {
+		log.Error("http fetcher is not set")
+		return
+	}
+
+	p.failures = 0
+	defer p.flushCache(log)
+
+	count, ok := p.resume(log)
+	if !ok {
+		return
+	}
+	// Количество подряд идущих записей, которые уже сохранены и не изменились
+	known := 0
 	// Парсит указанное количество страниц rss ленты сайта кремля.
 	// Сохраняет каждую страницу в отдельный файл.
 	// При каждом успешном парсинге возвращает ссылку на следующую страницу rss ленты.
 	// Делает паузу 5 секунд между парсингами.
 	for {
 
+		// Прерываем парсинг по сигналу, контрольная точка последней
+		// обработанной страницы уже сохранена
+		if ctx.Err() != nil {
+			log.Info("parsing interrupted", slog.Int("pages", count-1))
+			break
+		}
+
 		url := p.getUrl()
 
 		// Если url пустой, следующей достигнут конец RSS ленты,
@@ -79,83 +155,115 @@ func (p *Parser) Parse(ctx context.Context, log *slog.Logger) {
 
 		path := p.NewFilepath(url)
 
-		if count != p.PageCount || count != 1 {
-			log.Info("waiting", slog.String("parse_delay", p.Delay.String()))
-			time.Sleep(*p.Delay)
-		}
-
 		log.Debug("parsing url", slog.Any("url", url))
 
-		node, err := getTopicBody(url)
+		doc, err := p.fetchPage(ctx, log, url)
 
-		if os.IsTimeout(err) {
-			log.Error("server timeout error", sl.Err(err))
-			continue
+		if ctx.Err() != nil {
+			log.Info("parsing interrupted", slog.Int("pages", count-1))
+			break
 		}
+		// При разборе архива отсутствие страницы означает конец сохраненной ленты
+		if errors.Is(err, fetcher.ErrNotArchived) {
+			log.Info("archived pages are over", slog.String("url", url), slog.Int("pages", count-1))
+			break
+		}
+		// Без страницы неизвестен адрес следующей, поэтому обход ленты прекращается.
+		// При запуске с --resume обход продолжится с этой страницы
 		if err != nil {
-			log.Error("failed to decode request body", sl.Err(err))
-			continue
+			log.Error("feed parsing aborted", slog.String("url", url), slog.Int("pages", count-1), sl.Err(err))
+			break
 		}
 
-		p.parseMeta(node)
-		entries := p.parseEntries(node)
+		var entries []feed.Entry
+		if doc.NotModified {
+			// Страница не изменилась с прошлого обхода, все её записи уже сохранены
+			log.Debug("page not modified", slog.String("url", url))
+			p.parseMeta(doc)
+			known += p.StopAfterKnown
+		} else {
+			for _, err := range doc.Errors {
+				log.Warn("failed to decode feed element", slog.String("url", url), sl.Err(err))
+			}
+
+			p.parseMeta(doc)
+			entries = p.parseEntries(doc)
+			p.paginate(url, count, len(entries))
 
-		// Итерируемся по слайсу спарсеных entries, ищем по url запись в мантикоре,
-		// если записи нет nil, то делаем запись в мантикору
-		// todo сделать проверку и логику для update, когда запись есть но поля updated не совпадают
-		for _, e := range entries {
-			dbe, err := p.entries.Storage.FindByUrl(ctx, e.Url)
-			if err != nil {
-				log.Error("failed find entry by url", sl.Err(err))
+			// Записи страницы сохраняются вместе: один поиск по адресам
+			// и одна пакетная запись. Записи вне заданного периода публикации пропускаются
+			var page []*feed.Entry
+			for i := range entries {
+				if p.inPeriod(&entries[i]) {
+					page = append(page, &entries[i])
+				}
 			}
-			if dbe == nil {
-				id, err := p.entries.Storage.Insert(ctx, &e)
-				if err != nil {
-					log.Error(
-						"failed insert entry",
-						slog.Int64("id", *id),
-						slog.String("url", e.Url),
-						sl.Err(err),
-					)
+			failed := false
+			for _, status := range p.savePage(ctx, log, page) {
+				p.countStatus(status)
+				if status == entryUnchanged {
+					known++
+				} else {
+					known = 0
 				}
-				log.Info(
-					"entry successful inserted",
-					slog.Int64("id", *id),
-					slog.String("url", e.Url),
-				)
-			} else {
-				if !matchTimes(dbe, e) {
-					e.ID = dbe.ID
-					err = p.entries.Storage.Update(ctx, &e)
-					if err != nil {
-						log.Error(
-							"failed update entry",
-							slog.Int64("id", *e.ID),
-							slog.String("url", e.Url),
-							sl.Err(err),
-						)
-					} else {
-						log.Info(
-							"entry successful updated",
-							slog.Int64("id", *e.ID),
-							slog.String("url", e.Url),
-						)
-					}
+				failed = failed || status == entryFailed
+			}
+
+			// Валидаторы запоминаются, только если все записи страницы сохранены,
+			// иначе при следующем обходе сервер ответит 304 и запись будет потеряна
+			if !failed {
+				p.cacheValidators(url, doc)
+			}
+
+			p.syncStorage(log)
+
+			if p.SaveToFile {
+				if err := WriteJsonFile(entries, path); err != nil {
+					log.Error("failed to write page to file", slog.String("path", path), sl.Err(err))
+				} else {
+					log.Debug("path was successful writing", slog.Any("path", path))
 				}
 			}
 		}
 
-		if p.SaveToFile {
-			WriteJsonFile(log, entries, path)
+		p.saveCheckpoint(log, url, count)
+
+		// Записи в ленте идут от новых к старым, поэтому если вся страница
+		// опубликована раньше Since, на следующих страницах нужных записей нет
+		if p.olderThanPeriod(entries) {
+			log.Info("page is older than since, stop parsing", slog.String("since", p.Since.String()), slog.Int("pages", count))
+			break
 		}
 
-		if count == p.PageCount {
+		// В инкрементальном режиме количество страниц не ограничено,
+		// обход прекращается, когда подряд встретилось StopAfterKnown уже сохраненных
+		// и не изменившихся записей.
+		// Если задана нижняя граница периода, количество страниц также не ограничено.
+		// Страницы считаются от начала ленты, включая пройденные до контрольной точки,
+		// если контрольная точка уже дальше page_count, обход не начинается, см. resume
+		if p.Strategy == config.StrategyIncremental {
+			if known >= p.StopAfterKnown {
+				log.Info("reached known entries, stop parsing", slog.Int("known", known), slog.Int("pages", count))
+				break
+			}
+		} else if p.Since == nil && count >= p.PageCount {
 			break
 		}
 		count++
 	}
 }
 
+// wait ждет, пока можно будет сделать запрос по адресу url, соблюдая паузу между
+// запросами к хосту. Возвращает ошибку, если ожидание прервано контекстом,
+// или ErrDisallowed, если адрес запрещен robots.txt.
+func (p *Parser) wait(ctx context.Context, log *slog.Logger, url string) error {
+	if err := p.checkRobots(ctx, log, url); err != nil {
+		return err
+	}
+	log.Debug("waiting", slog.String("url", url), slog.String("parse_delay", p.Limiter.Delay(url).String()))
+	return p.Limiter.Wait(ctx, url)
+}
+
 func (p *Parser) getUrl() string {
 	var url string
 	// Если Meta только инициализирован, то Meta.Self и Meta.Next пусты,
@@ -169,116 +277,95 @@ func (p *Parser) getUrl() string {
 	return url
 }
 
+// paginate дополняет навигационную информацию страницы для лент без ссылок rel="next".
+//
+// Если у страницы нет ссылки на себя, ею считается загруженный url,
+// иначе getUrl вернет начальный адрес и парсер зациклится на первой странице.
+// Если ссылки на следующую страницу нет, а для начального адреса задан шаблон
+// PageURL, адрес следующей страницы строится подстановкой её номера вместо {page}.
+// Обход прекращается на первой странице без записей.
+func (p *Parser) paginate(url string, page int, entries int) {
+	if p.Meta.Self == "" {
+		p.Meta.Self = url
+	}
+	if p.Meta.Next != "" || p.PageURL == "" || entries == 0 {
+		return
+	}
+	p.Meta.Next = strings.ReplaceAll(p.PageURL, "{page}", strconv.Itoa(page+1))
+}
+
 func (p *Parser) NewFilepath(url string) string {
 	file := fmt.Sprintf("%v/%v.json", p.OutputPath, slug.Make(url))
 	file = filepath.Clean(file)
 	return file
 }
 
-func getTopicBody(url string) (*html.Node, error) {
+// getTopicBody загружает страницу ленты по адресу url и декодирует её.
+// Если для страницы сохранены валидаторы, запрос делается условным,
+// и при ответе 304 Not Modified возвращается документ с NotModified.
+func (p *Parser) getTopicBody(ctx context.Context, url string) (*document, error) {
+	header := http.Header{}
+	cached, ok := p.cachedValidators(url)
+	if ok {
+		cached.SetHeaders(header)
+	}
 
-	resp, err := call(url)
+	pages := p.Pages
+	if pages == nil {
+		pages = p.Fetcher
+	}
+	resp, err := pages.Fetch(ctx, url, header)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("status code error: %d %s\r\n", resp.StatusCode, resp.Status)
-		return nil, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	if ok && resp.StatusCode == http.StatusNotModified {
+		return &document{NotModified: true, Meta: Meta{Self: url, Next: cached.Next}}, nil
 	}
-	doc, err := html.Parse(resp.Body)
-
-	if err != nil {
-		log.Fatalln(err) // Handle error
-	}
-	return doc, nil
-}
-
-// call is a Go function that makes a GET request to the provided URL and returns the response and an error, if any.
-//
-// It takes a string 'url' as a parameter and returns a pointer to http.Response and an error.
-func call(url string) (*http.Response, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp)
 	}
-
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	// Без user-agent kremlin.ru не отдает данные
-	req.Header.Add("User-Agent", "TestProgram/0.01")
-	resp, err := client.Do(req)
-
+	doc, err := decodeFeed(data)
 	if err != nil {
 		return nil, err
 	}
-
-	return resp, err
-}
-
-// getInnerText returns the inner text of the HTML node.
-//
-// It takes a pointer to a html.Node as a parameter and returns a string.
-func getInnerText(node *html.Node) string {
-	for el := node.FirstChild; el != nil; el = el.NextSibling {
-		if el.Type == html.TextNode {
-			return el.Data
-		}
-	}
-	return ""
-}
-
-// nodeHasRequiredRelAttr checks if the given html.Node has the required rel attribute.
-//
-// It takes a string rcc and a *html.Node n as parameters and returns a boolean.
-func nodeHasRequiredRelAttr(rcc string, n *html.Node) bool {
-	for _, attr := range n.Attr {
-		if attr.Key == "rel" && attr.Val == rcc {
-			return true
-		}
-	}
-	return false
+	doc.Validators = httpcache.FromResponse(resp)
+	return doc, nil
 }
 
-// getRequiredDataAttr returns the value of the specified attribute from the given html.Node.
-//
-// rda string - the attribute key to search for.
-// n *html.Node - the html node to search within.
-// string - the value of the specified attribute, or an empty string if not found.
-func getRequiredDataAttr(rda string, n *html.Node) string {
-	for _, attr := range n.Attr {
-		if attr.Key == rda {
-			return attr.Val
-		}
-	}
-	return ""
-}
+// WriteJsonFile атомарно записывает записи страницы ленты в json файл outputPath.
+func WriteJsonFile(entries []feed.Entry, outputPath string) error {
+	const op = "parser.WriteJsonFile"
 
-func checkError(message string, err error) {
+	aJson, err := json.MarshalIndent(entries, "", "\t")
 	if err != nil {
-		log.Fatal(message, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := atomicfile.WriteFile(outputPath, aJson); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	return nil
 }
 
-func WriteJsonFile(logger *slog.Logger, entries []feed.Entry, outputPath string) {
-	// Create file
-	file, err := os.Create(outputPath)
-	checkError("Cannot create file", err)
-	defer file.Close()
-
-	aJson, err := json.MarshalIndent(entries, "", "\t")
-	if err != nil {
-		log.Fatal(err)
+// syncStorage сбрасывает на диск записи страницы, если хранилище их буферизует.
+func (p *Parser) syncStorage(log *slog.Logger) {
+	if s, ok := p.entries.Storage.(feed.Syncer); ok {
+		if err := s.Sync(); err != nil {
+			log.Error("failed to sync storage", sl.Err(err))
+		}
 	}
-	_, err = file.Write(aJson)
-	checkError("Cannot write to the file", err)
-	logger.Debug("path was successful writing", slog.Any("path", outputPath))
 }
 
 func matchTimes(dbe *feed.Entry, e feed.Entry) bool {
+	if dbe.Updated == nil || e.Updated == nil {
+		return false
+	}
 	// Приводим время в обоих объектах к GMT+4, как на сайте Кремля
 	loc, _ := time.LoadLocation("Etc/GMT-4")
 	dbeTime := dbe.Updated.In(loc)
@@ -0,0 +1,309 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/terratensor/kremlin-parser/internal/checkpoint"
+	"github.com/terratensor/kremlin-parser/internal/config"
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/fetcher"
+	"github.com/terratensor/kremlin-parser/internal/lib/ratelimit"
+	"github.com/terratensor/kremlin-parser/internal/storage/memory"
+)
+
+// perPage количество записей на странице тестовой ленты.
+const perPage = 2
+
+// testFeed лента Atom из pages страниц, записи идут от новых к старым,
+// каждая следующая запись опубликована на день раньше предыдущей.
+type testFeed struct {
+	mu      sync.Mutex
+	srv     *httptest.Server
+	pages   int
+	start   time.Time
+	titles  map[int]string
+	updated map[int]time.Time
+	hits    map[int]int
+	// media количество запросов вложений
+	media int
+}
+
+func newTestFeed(t *testing.T, pages int) *testFeed {
+	f := &testFeed{
+		pages:   pages,
+		start:   time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+		titles:  map[int]string{},
+		updated: map[int]time.Time{},
+		hits:    map[int]int{},
+	}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// published время публикации записи n, записи нумеруются с 0.
+func (f *testFeed) published(n int) time.Time {
+	return f.start.AddDate(0, 0, -n)
+}
+
+// edit меняет заголовок и время обновления записи n.
+func (f *testFeed) edit(n int, title string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.titles[n] = title
+	f.updated[n] = f.published(n).Add(time.Hour)
+}
+
+// requests возвращает количество запросов каждой страницы и сбрасывает счетчик.
+func (f *testFeed) requests() map[int]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hits := f.hits
+	f.hits = map[int]int{}
+	return hits
+}
+
+func (f *testFeed) url() string {
+	return f.pageURL(1)
+}
+
+func (f *testFeed) pageURL(page int) string {
+	return f.srv.URL + "/feed/page/" + strconv.Itoa(page)
+}
+
+func (f *testFeed) serve(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/media/") {
+		f.mu.Lock()
+		f.media++
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = io.WriteString(w, r.URL.Path)
+		return
+	}
+
+	page, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/feed/page/"))
+	if err != nil || page < 1 || page > f.pages {
+		http.NotFound(w, r)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hits[page]++
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?><feed xmlns="http://www.w3.org/2005/Atom">`)
+	fmt.Fprintf(&b, `<link rel="self" href="%s"/>`, f.pageURL(page))
+	if page < f.pages {
+		fmt.Fprintf(&b, `<link rel="next" href="%s"/>`, f.pageURL(page+1))
+	}
+	for n := (page - 1) * perPage; n < page*perPage; n++ {
+		title, ok := f.titles[n]
+		if !ok {
+			title = fmt.Sprintf("Запись %d", n)
+		}
+		updated, ok := f.updated[n]
+		if !ok {
+			updated = f.published(n)
+		}
+		fmt.Fprintf(&b, `<entry><id>http://kremlin.ru/events/president/news/%d</id><title>%s</title>`, 1000-n, title)
+		fmt.Fprintf(&b, `<published>%s</published><updated>%s</updated>`, f.published(n).Format(time.RFC3339), updated.Format(time.RFC3339))
+		fmt.Fprintf(&b, `<link rel="enclosure" href="%s/media/%d.jpg" type="image/jpeg"/>`, f.srv.URL, n)
+		b.WriteString(`<content type="html">&lt;p&gt;Текст&lt;/p&gt;</content></entry>`)
+	}
+	b.WriteString(`</feed>`)
+
+	w.Header().Set("Content-Type", "application/atom+xml")
+	_, _ = io.WriteString(w, b.String())
+}
+
+// newTestParser создает парсер ленты f, сохраняющий записи в store.
+func newTestParser(t *testing.T, f *testFeed, store feed.StorageInterface) *Parser {
+	client, err := fetcher.New(config.HTTP{UserAgent: "test", Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Parser{
+		Lang:           "ru",
+		URI:            f.url(),
+		PageCount:      f.pages,
+		Strategy:       config.StrategyPages,
+		StopAfterKnown: perPage,
+		Limiter:        ratelimit.New(0),
+		Fetcher:        client,
+		Meta:           NewMeta(),
+		entries:        feed.NewFeedStorage(store),
+	}
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestParse(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFeed(t, 3)
+	store := memory.New(nil)
+
+	p := newTestParser(t, f, store)
+	p.Parse(ctx, testLogger())
+	if got := p.progress; got.Inserted != 6 || got.Updated != 0 || got.Unchanged != 0 {
+		t.Fatalf("first pass: got %+v, want 6 inserted", got)
+	}
+	if n, _ := store.Count(ctx, feed.Filter{}); n != 6 {
+		t.Fatalf("stored %d entries, want 6", n)
+	}
+
+	f.edit(3, "Исправленная запись")
+	p = newTestParser(t, f, store)
+	p.Parse(ctx, testLogger())
+	if got := p.progress; got.Inserted != 0 || got.Updated != 1 || got.Unchanged != 5 {
+		t.Fatalf("second pass: got %+v, want 1 updated and 5 unchanged", got)
+	}
+
+	e, err := store.FindByUrl(ctx, "http://kremlin.ru/events/president/news/997")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Title != "Исправленная запись" {
+		t.Errorf("updated title: got %q", e.Title)
+	}
+	if e.EventKey != "kremlin.ru/events/president/news/997" || e.Language != "ru" {
+		t.Errorf("event key and language: got %q and %q", e.EventKey, e.Language)
+	}
+}
+
+func TestParseIncremental(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFeed(t, 4)
+	store := memory.New(nil)
+
+	newTestParser(t, f, store).Parse(ctx, testLogger())
+	f.requests()
+
+	// Измененная запись на первой странице сбрасывает счетчик известных записей,
+	// поэтому обход останавливается только после второй страницы
+	f.edit(1, "Исправленная запись")
+	p := newTestParser(t, f, store)
+	p.Strategy = config.StrategyIncremental
+	p.Parse(ctx, testLogger())
+
+	hits := f.requests()
+	if hits[1] != 1 || hits[2] != 1 || hits[3] != 0 {
+		t.Errorf("requested pages %v, want 1-2", hits)
+	}
+	if got := p.progress; got.Updated != 1 || got.Unchanged != 3 {
+		t.Errorf("got %+v, want 1 updated and 3 unchanged", got)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFeed(t, 5)
+	store := memory.New(nil)
+
+	// Since отсекает записи старше третьей: страница 2 попадает в период частично,
+	// страница 3 целиком старше Since, дальше неё обход не идет
+	since := f.published(2)
+	p := newTestParser(t, f, store)
+	p.PageCount = 1
+	p.Since = &since
+	p.Parse(ctx, testLogger())
+
+	hits := f.requests()
+	if hits[3] != 1 || hits[4] != 0 {
+		t.Errorf("requested pages %v, want 1-3", hits)
+	}
+	if n, _ := store.Count(ctx, feed.Filter{}); n != 3 {
+		t.Errorf("stored %d entries, want 3", n)
+	}
+	if e, _ := store.FindByUrl(ctx, "http://kremlin.ru/events/president/news/997"); e != nil {
+		t.Errorf("entry published before since is stored: %s", e.Url)
+	}
+}
+
+func TestParseKeepsDownloads(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFeed(t, 1)
+	store := memory.New(nil)
+
+	p := newTestParser(t, f, store)
+	p.DownloadAttachments = true
+	p.OutputPath = t.TempDir()
+	p.Parse(ctx, testLogger())
+	if f.media != perPage {
+		t.Fatalf("downloaded %d attachments, want %d", f.media, perPage)
+	}
+
+	// Обновленная запись сохраняет загруженное вложение и не загружает его повторно
+	f.edit(0, "Исправленная запись")
+	outputPath := p.OutputPath
+	p = newTestParser(t, f, store)
+	p.DownloadAttachments = true
+	p.OutputPath = outputPath
+	p.Parse(ctx, testLogger())
+	if f.media != perPage {
+		t.Errorf("downloaded %d attachments after update, want %d", f.media, perPage)
+	}
+
+	e, err := store.FindByUrl(ctx, "http://kremlin.ru/events/president/news/1000")
+	if err != nil || e == nil {
+		t.Fatalf("FindByUrl = %v, %v", e, err)
+	}
+	if e.Title != "Исправленная запись" || len(e.Attachments) != 1 || e.Attachments[0].Path == "" {
+		t.Errorf("updated entry: got title %q and attachments %+v", e.Title, e.Attachments)
+	}
+}
+
+func TestParseResume(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFeed(t, 5)
+	store := memory.New(nil)
+	checkpoints, err := checkpoint.New(filepath.Join(t.TempDir(), "checkpoints.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestParser(t, f, store)
+	p.PageCount = 2
+	p.Resume = true
+	p.Checkpoints = checkpoints
+	p.Parse(ctx, testLogger())
+	if hits := f.requests(); hits[1] != 1 || hits[2] != 1 || hits[3] != 0 {
+		t.Fatalf("first pass requested pages %v, want 1-2", hits)
+	}
+
+	// Контрольная точка уже на page_count, загружать нечего
+	p = newTestParser(t, f, store)
+	p.PageCount = 2
+	p.Resume = true
+	p.Checkpoints = checkpoints
+	p.Parse(ctx, testLogger())
+	if hits := f.requests(); len(hits) != 0 {
+		t.Errorf("resume at page_count requested pages %v, want none", hits)
+	}
+
+	// Обход продолжается со страницы после контрольной точки до page_count
+	p = newTestParser(t, f, store)
+	p.PageCount = 4
+	p.Resume = true
+	p.Checkpoints = checkpoints
+	p.Parse(ctx, testLogger())
+	if hits := f.requests(); hits[1] != 0 || hits[2] != 0 || hits[3] != 1 || hits[4] != 1 || hits[5] != 0 {
+		t.Errorf("resume requested pages %v, want 3-4", hits)
+	}
+	if cp, _ := checkpoints.Get(f.url()); cp.Pages != 4 {
+		t.Errorf("checkpoint pages: got %d, want 4", cp.Pages)
+	}
+}
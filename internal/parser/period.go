@@ -0,0 +1,32 @@
+package parser
+
+import (
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+)
+
+// inPeriod проверяет, что запись опубликована в периоде [Since, Until).
+func (p *Parser) inPeriod(e *feed.Entry) bool {
+	if e.Published == nil {
+		return true
+	}
+	if p.Since != nil && e.Published.Before(*p.Since) {
+		return false
+	}
+	if p.Until != nil && !e.Published.Before(*p.Until) {
+		return false
+	}
+	return true
+}
+
+// olderThanPeriod проверяет, что все записи страницы опубликованы раньше Since.
+func (p *Parser) olderThanPeriod(entries []feed.Entry) bool {
+	if p.Since == nil || len(entries) == 0 {
+		return false
+	}
+	for _, e := range entries {
+		if e.Published == nil || !e.Published.Before(*p.Since) {
+			return false
+		}
+	}
+	return true
+}
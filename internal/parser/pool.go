@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// ParseAll парсит ленты параллельно, каждую в отдельной горутине.
+// Одновременно обрабатывается не более concurrency лент,
+// остальные ждут своей очереди. Возвращается после завершения всех лент.
+func ParseAll(ctx context.Context, log *slog.Logger, parsers []*Parser, concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	sem := make(chan struct{}, concurrency)
+	wg := &sync.WaitGroup{}
+
+	for _, prs := range parsers {
+		wg.Add(1)
+		go func(prs *Parser) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			prs.Parse(ctx, log)
+		}(prs)
+	}
+
+	wg.Wait()
+}
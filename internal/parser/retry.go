@@ -0,0 +1,162 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/terratensor/kremlin-parser/internal/config"
+	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
+)
+
+// ErrBudgetExhausted возвращается, когда за время обхода ленты
+// количество неудачных запросов превысило бюджет ошибок.
+var ErrBudgetExhausted = errors.New("error budget exhausted")
+
+// StatusError ответ сервера с кодом, отличным от 200 OK.
+type StatusError struct {
+	Code   int
+	Status string
+	// RetryAfter пауза из заголовка Retry-After, 0 если заголовка нет
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code error: %d %s", e.Code, e.Status)
+}
+
+// newStatusError создает StatusError по ответу сервера.
+func newStatusError(resp *http.Response) *StatusError {
+	return &StatusError{
+		Code:       resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// PageError ошибка загрузки страницы ленты после всех попыток.
+type PageError struct {
+	URL      string
+	Attempts int
+	Err      error
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("page %s failed after %d attempts: %v", e.URL, e.Attempts, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// fetchPage загружает и декодирует страницу ленты, повторяя запрос при временных
+// ошибках с экспоненциально растущей паузой.
+//
+// Каждая неудачная попытка расходует бюджет ошибок обхода ленты. Если попытки
+// закончились, ошибка не временная или бюджет исчерпан, возвращается *PageError.
+func (p *Parser) fetchPage(ctx context.Context, log *slog.Logger, url string) (*document, error) {
+	for attempt := 1; ; attempt++ {
+		if err := p.wait(ctx, log, url); err != nil {
+			return nil, err
+		}
+
+		doc, err := p.getTopicBody(ctx, url)
+		if err == nil {
+			return doc, nil
+		}
+		// Запрос прерван завершением работы, а не ошибкой сервера
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		p.failures++
+		if p.failures >= p.Retry.ErrorBudget {
+			return nil, &PageError{URL: url, Attempts: attempt, Err: fmt.Errorf("%w: %w", ErrBudgetExhausted, err)}
+		}
+		if !isRetryable(err) || attempt >= p.Retry.MaxAttempts {
+			return nil, &PageError{URL: url, Attempts: attempt, Err: err}
+		}
+
+		delay := backoff(p.Retry, attempt, err)
+		log.Warn(
+			"failed to fetch page, retrying",
+			slog.String("url", url),
+			slog.Int("attempt", attempt),
+			slog.String("backoff", delay.String()),
+			sl.Err(err),
+		)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// isRetryable проверяет, имеет ли смысл повторить запрос:
+// таймауты, сетевые ошибки, 429 Too Many Requests и ошибки сервера 5xx.
+func isRetryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code == http.StatusTooManyRequests || se.Code >= http.StatusInternalServerError
+	}
+	if os.IsTimeout(err) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	var oe *net.OpError
+	return errors.As(err, &oe)
+}
+
+// backoff возвращает паузу перед следующей попыткой: InitialBackoff * 2^(attempt-1),
+// но не больше MaxBackoff, со случайным разбросом в пределах половины паузы.
+// Если сервер прислал Retry-After и он больше, используется он.
+func backoff(r config.Retry, attempt int, err error) time.Duration {
+	d := r.InitialBackoff
+	for i := 1; i < attempt && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) && se.RetryAfter > d {
+		d = se.RetryAfter
+	}
+	return d
+}
+
+// parseRetryAfter разбирает заголовок Retry-After: количество секунд или дату HTTP.
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
+)
+
+// ErrDisallowed возвращается, если загрузка адреса запрещена robots.txt.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// checkRobots проверяет, разрешает ли robots.txt хоста загружать url.
+// Пропущенные адреса логируются. Если robots.txt загрузить не удалось,
+// адрес считается разрешенным.
+func (p *Parser) checkRobots(ctx context.Context, log *slog.Logger, url string) error {
+	if p.Robots == nil {
+		return nil
+	}
+
+	allowed, err := p.Robots.Allowed(ctx, url)
+	if err != nil && ctx.Err() == nil {
+		log.Warn("failed to check robots.txt", slog.String("url", url), sl.Err(err))
+	}
+	if !allowed {
+		log.Warn("url skipped, disallowed by robots.txt", slog.String("url", url))
+		return fmt.Errorf("%w: %s", ErrDisallowed, url)
+	}
+	return nil
+}
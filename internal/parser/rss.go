@@ -0,0 +1,302 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+)
+
+// rssLink элемент <link> канала или записи RSS.
+// В RSS ссылка передается текстом элемента, а в <atom:link> — атрибутами,
+// поэтому оба варианта собираются в один тип и различаются по XMLName.Space.
+type rssLink struct {
+	XMLName xml.Name
+	Rel     string `xml:"rel,attr"`
+	Href    string `xml:"href,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// rssGUID элемент <guid> записи RSS 2.0.
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// rssCategory элемент <category> записи RSS 2.0 или dc:subject записи RSS 1.0.
+type rssCategory struct {
+	Domain string `xml:"domain,attr"`
+	Value  string `xml:",chardata"`
+}
+
+// rssEnclosure элемент <enclosure> записи RSS 2.0 или <media:content> Media RSS.
+type rssEnclosure struct {
+	Url    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+	Size   string `xml:"fileSize,attr"`
+	Title  string `xml:"http://search.yahoo.com/mrss/ title"`
+}
+
+// rssItem элемент <item> RSS 2.0 или RSS 1.0 (RDF).
+type rssItem struct {
+	About       string         `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string         `xml:"title"`
+	Links       []rssLink      `xml:"link"`
+	GUID        rssGUID        `xml:"guid"`
+	PubDate     string         `xml:"pubDate"`
+	Date        string         `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Updated     string         `xml:"http://www.w3.org/2005/Atom updated"`
+	Author      string         `xml:"author"`
+	Creators    []string       `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []rssCategory  `xml:"category"`
+	Subjects    []rssCategory  `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	Enclosures  []rssEnclosure `xml:"enclosure"`
+	Media       []rssEnclosure `xml:"http://search.yahoo.com/mrss/ content"`
+	Description string         `xml:"description"`
+	Encoded     string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+// rssTimeLayouts форматы дат, встречающиеся в pubDate лент RSS 2.0.
+// Помимо RFC 1123 многие ленты опускают день недели или секунды.
+var rssTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	time.RFC3339,
+}
+
+// decodeRSS потоково декодирует ленту RSS 2.0 или RSS 1.0 (RDF) из r.
+//
+// Как и в decodeAtom, ошибки отдельных записей не прерывают разбор
+// и собираются в document.Errors.
+func decodeRSS(r io.Reader) (*document, error) {
+	const op = "parser.decodeRSS"
+
+	doc := &document{}
+	d := newXMLDecoder(r)
+
+	// Стек имен открытых элементов, нужен для того, чтобы отличать
+	// элементы канала от одноименных элементов записи
+	var stack []string
+	index := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 {
+				switch t.Name.Local {
+				case "rss":
+					doc.Format = FormatRSS
+				case "RDF":
+					doc.Format = FormatRDF
+				default:
+					return nil, fmt.Errorf("%s: %w: <%s>", op, ErrUnknownFormat, t.Name.Local)
+				}
+			}
+
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+
+			switch {
+			case t.Name.Local == "item":
+				if err := doc.decodeRSSItem(d, t, &index); err != nil {
+					return nil, fmt.Errorf("%s: %w", op, err)
+				}
+				continue
+			case parent == "channel":
+				if err := doc.decodeRSSChannelElement(d, t); err != nil {
+					return nil, fmt.Errorf("%s: %w", op, err)
+				}
+				continue
+			}
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+// decodeRSSChannelElement декодирует дочерний элемент <channel>.
+// Возвращает ошибку только в случае синтаксической ошибки XML.
+func (doc *document) decodeRSSChannelElement(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "link":
+		var l rssLink
+		if err := d.DecodeElement(&l, &start); err != nil {
+			return err
+		}
+		if l.XMLName.Space == atomNS {
+			doc.Meta.setLink(l.Rel, l.Href)
+		} else if doc.Meta.ID == "" {
+			doc.Meta.ID = strings.TrimSpace(l.Text)
+		}
+	case "lastBuildDate", "pubDate", "date":
+		var s string
+		if err := d.DecodeElement(&s, &start); err != nil {
+			return err
+		}
+		// lastBuildDate точнее отражает время обновления ленты, чем pubDate
+		if doc.Meta.Updated != nil && start.Name.Local != "lastBuildDate" {
+			return nil
+		}
+		t, err := parseRSSTime(s)
+		if err != nil {
+			doc.Errors = append(doc.Errors, &DecodeError{Element: "channel/" + start.Name.Local, Err: err})
+			return nil
+		}
+		doc.Meta.Updated = t
+	default:
+		return d.Skip()
+	}
+	return nil
+}
+
+// decodeRSSItem декодирует элемент <item> и добавляет запись в документ.
+func (doc *document) decodeRSSItem(d *xml.Decoder, start xml.StartElement, index *int) error {
+	var item rssItem
+	if err := d.DecodeElement(&item, &start); err != nil {
+		return err
+	}
+	*index++
+
+	e, err := item.toEntry()
+	if err != nil {
+		doc.Errors = append(doc.Errors, &DecodeError{Element: "item", Index: *index, ID: item.id(), Err: err})
+		return nil
+	}
+	doc.Entries = append(doc.Entries, *e)
+	return nil
+}
+
+// id возвращает идентификатор записи: guid, rdf:about или ссылку.
+func (item rssItem) id() string {
+	if guid := strings.TrimSpace(item.GUID.Value); guid != "" {
+		return guid
+	}
+	if item.About != "" {
+		return item.About
+	}
+	return item.link()
+}
+
+// link возвращает адрес записи. Если ссылки нет, используется guid,
+// при условии что он является постоянной ссылкой.
+func (item rssItem) link() string {
+	for _, l := range item.Links {
+		if l.XMLName.Space == atomNS {
+			if l.Rel == "" || l.Rel == "alternate" {
+				return l.Href
+			}
+			continue
+		}
+		if s := strings.TrimSpace(l.Text); s != "" {
+			return s
+		}
+	}
+	guid := strings.TrimSpace(item.GUID.Value)
+	if item.GUID.IsPermaLink != "false" && strings.HasPrefix(guid, "http") {
+		return guid
+	}
+	return item.About
+}
+
+// toEntry преобразует запись RSS в feed.Entry.
+func (item rssItem) toEntry() (*feed.Entry, error) {
+	e := &feed.Entry{
+		Title:   strings.TrimSpace(item.Title),
+		Url:     item.link(),
+		Content: strings.TrimSpace(item.Encoded),
+		Summary: strings.TrimSpace(item.Description),
+	}
+	if e.Url == "" {
+		return nil, fmt.Errorf("link: empty")
+	}
+	// В RSS 2.0 author содержит адрес почты, поэтому предпочтительнее dc:creator
+	for _, c := range item.Creators {
+		e.Author = joinAuthors(e.Author, c)
+	}
+	if e.Author == "" {
+		e.Author = strings.TrimSpace(item.Author)
+	}
+	for _, c := range append(item.Categories, item.Subjects...) {
+		addTerm(e, term{Ref: c.Domain, Name: c.Value})
+	}
+	for _, enc := range append(item.Enclosures, item.Media...) {
+		size := enc.Length
+		if size == "" {
+			size = enc.Size
+		}
+		addAttachment(e, feed.Attachment{
+			Url:      enc.Url,
+			MimeType: enc.Type,
+			Size:     parseLength(size),
+			Caption:  enc.Title,
+		})
+	}
+	// Если полного текста в content:encoded нет, текстом записи считается description
+	if e.Content == "" {
+		e.Content, e.Summary = e.Summary, ""
+	}
+
+	var err error
+	switch {
+	case item.PubDate != "":
+		if e.Published, err = parseRSSTime(item.PubDate); err != nil {
+			return nil, fmt.Errorf("pubDate: %w", err)
+		}
+	case item.Date != "":
+		if e.Published, err = parseRSSTime(item.Date); err != nil {
+			return nil, fmt.Errorf("date: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("pubDate: empty")
+	}
+
+	e.Updated = e.Published
+	if item.Updated != "" {
+		if e.Updated, err = parseAtomTime(item.Updated); err != nil {
+			return nil, fmt.Errorf("updated: %w", err)
+		}
+	}
+
+	return e, nil
+}
+
+// parseRSSTime разбирает дату записи RSS.
+// Поддерживаются форматы RFC 1123 с вариациями и W3CDTF (dc:date).
+func parseRSSTime(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range rssTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t, nil
+		}
+	}
+	// dc:date может содержать только дату
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported date format %q", s)
+	}
+	return &t, nil
+}
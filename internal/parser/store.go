@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/lib/logger/sl"
+	"github.com/terratensor/kremlin-parser/internal/storage"
+)
+
+// entryStatus результат сохранения записи.
+type entryStatus int
+
+const (
+	entryInserted  entryStatus = iota // новая запись добавлена в хранилище
+	entryUpdated                      // запись изменилась и обновлена
+	entryUnchanged                    // запись уже сохранена и не изменилась
+	entryFailed                       // запись не удалось сохранить
+)
+
+// savePage сохраняет записи страницы ленты и возвращает результат сохранения каждой.
+//
+// Сохраненные записи и их версии на другом языке ищутся в хранилище одним
+// запросом по адресам. Записи, которых нет, добавляются, записи, у которых
+// не совпадает поле updated (или установлен Force), обновляются. Новые
+// и измененные записи перед сохранением дополняются текстом со страницы
+// материала, загруженными вложениями и ссылкой на версию на другом языке
+// и сохраняются одним вызовом Bulk вместе с версиями на другом языке,
+// в которые записывается обратная ссылка. Если хранилище не назначает
+// идентификаторы новых записей заранее (feed.IDAssigner), обратные ссылки
+// на новые записи сохраняются вторым вызовом Bulk после того, как
+// идентификаторы станут известны.
+func (p *Parser) savePage(ctx context.Context, log *slog.Logger, entries []*feed.Entry) []entryStatus {
+	statuses := make([]entryStatus, len(entries))
+	if len(entries) == 0 {
+		return statuses
+	}
+
+	urls := make([]string, 0, 2*len(entries))
+	for _, e := range entries {
+		urls = append(urls, e.Url)
+		if e.CounterpartUrl != "" {
+			urls = append(urls, e.CounterpartUrl)
+		}
+	}
+	found, err := p.entries.Storage.FindByUrls(ctx, urls)
+	if err != nil {
+		log.Error("failed find entries by url", sl.Err(err))
+		for i := range statuses {
+			statuses[i] = entryFailed
+		}
+		return statuses
+	}
+	stored := make(map[string]*feed.Entry, len(found))
+	for i := range found {
+		stored[found[i].Url] = &found[i]
+	}
+	assigner, _ := p.entries.Storage.(feed.IDAssigner)
+
+	var (
+		batch []feed.Entry
+		// index индекс каждой записи batch в entries, для обратных ссылок — -1
+		index []int
+		// later версии на другом языке для новых записей без идентификатора
+		later []*feed.Entry
+		// linked адреса версий на другом языке, уже добавленных в batch
+		linked = make(map[string]bool)
+	)
+	for i, e := range entries {
+		statuses[i] = entryInserted
+		dbe := stored[e.Url]
+		if dbe != nil {
+			if !p.Force && matchTimes(dbe, *e) {
+				statuses[i] = entryUnchanged
+				continue
+			}
+			statuses[i] = entryUpdated
+			e.ID = dbe.ID
+		} else if assigner != nil {
+			id := assigner.AssignID(e)
+			e.ID = &id
+		}
+
+		p.enrich(ctx, log, e)
+		if dbe != nil && !p.FetchArticles {
+			keepArticle(e, dbe)
+		}
+		var cp *feed.Entry
+		if dbe != nil {
+			keepDownloads(e, dbe)
+			e.CounterpartID = dbe.CounterpartID
+		}
+		p.downloadAttachments(ctx, log, e)
+		if e.CounterpartID == nil {
+			cp = p.findCounterpart(e, stored)
+		}
+
+		batch = append(batch, *e)
+		index = append(index, i)
+
+		if cp == nil || linked[cp.Url] {
+			continue
+		}
+		if e.ID == nil {
+			later = append(later, cp)
+			continue
+		}
+		if link := linkCounterpart(e, cp); link != nil {
+			linked[link.Url] = true
+			batch = append(batch, *link)
+			index = append(index, -1)
+		}
+	}
+	if len(batch) == 0 {
+		return statuses
+	}
+
+	var bulkErr *storage.BulkError
+	if err := p.entries.Storage.Bulk(ctx, &batch); err != nil && !errors.As(err, &bulkErr) {
+		log.Error("failed save page entries", slog.Int("entries", len(batch)), sl.Err(err))
+		for _, i := range index {
+			if i >= 0 {
+				statuses[i] = entryFailed
+			}
+		}
+		return statuses
+	}
+
+	for j := range batch {
+		i, e := index[j], &batch[j]
+		var itemErr error
+		if bulkErr != nil {
+			itemErr = bulkErr.Items[j]
+		}
+
+		if i < 0 {
+			logLink(log, e, itemErr)
+			continue
+		}
+		if itemErr != nil {
+			log.Error("failed save entry", slog.String("url", e.Url), sl.Err(itemErr))
+			if statuses[i] == entryInserted {
+				entries[i].ID = nil
+			}
+			statuses[i] = entryFailed
+			continue
+		}
+
+		entries[i].ID = e.ID
+		msg := "entry successful inserted"
+		if statuses[i] == entryUpdated {
+			msg = "entry successful updated"
+		}
+		log.Info(msg, slog.Int64("id", *e.ID), slog.String("url", e.Url))
+	}
+
+	if len(later) > 0 {
+		p.saveCounterparts(ctx, log, entries, statuses, later)
+	}
+
+	return statuses
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+)
+
+// catalogRe находит раздел каталога kremlin.ru в ссылке на элемент каталога,
+// например /catalog/persons/212/events или /catalog/regions/MOW/events.
+var catalogRe = regexp.MustCompile(`/catalog/(persons|regions|countries|keywords|glossary)/[^/?#]+`)
+
+// term значение таксономии: ссылка на элемент каталога (или схема) и название.
+type term struct {
+	Ref  string
+	Name string
+}
+
+// addTerm добавляет значение таксономии в соответствующее поле записи.
+// Раздел определяется по ссылке на каталог kremlin.ru, значения без
+// ссылки на каталог считаются рубриками.
+func addTerm(e *feed.Entry, t term) {
+	name := strings.Join(strings.Fields(t.Name), " ")
+	if name == "" {
+		return
+	}
+
+	section := ""
+	if m := catalogRe.FindStringSubmatch(t.Ref); m != nil {
+		section = m[1]
+	}
+
+	switch section {
+	case "persons":
+		e.Persons = appendUnique(e.Persons, name)
+	case "regions", "countries":
+		e.Regions = appendUnique(e.Regions, name)
+	case "keywords", "glossary":
+		e.Tags = appendUnique(e.Tags, name)
+	default:
+		e.Categories = appendUnique(e.Categories, name)
+	}
+}
+
+// appendUnique добавляет значение в слайс, если его там ещё нет.
+func appendUnique(values []string, v string) []string {
+	for _, s := range values {
+		if strings.EqualFold(s, v) {
+			return values
+		}
+	}
+	return append(values, v)
+}
@@ -0,0 +1,131 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+	"github.com/terratensor/kremlin-parser/internal/fetcher"
+	"github.com/terratensor/kremlin-parser/internal/lib/ratelimit"
+)
+
+// ttl время, в течение которого загруженный robots.txt считается актуальным.
+// RFC 9309 рекомендует не кешировать файл дольше 24 часов.
+const ttl = 24 * time.Hour
+
+// errorTTL время, в течение которого действует запрет обхода хоста после
+// ответа 5xx на запрос robots.txt: ошибка сервера считается временной.
+const errorTTL = 10 * time.Minute
+
+// Checker загружает robots.txt каждого хоста и проверяет, разрешено ли
+// загружать адрес. Директива Crawl-delay передается в Limiter и действует,
+// если она больше паузы parse_delay. Безопасен для одновременного использования.
+type Checker struct {
+	fetcher fetcher.Fetcher
+	agent   string
+	limiter *ratelimit.Limiter
+
+	mu    sync.Mutex
+	hosts map[string]*host
+}
+
+// host правила robots.txt хоста. mu удерживается на время загрузки файла,
+// чтобы параллельные парсеры не загружали его одновременно.
+type host struct {
+	mu      sync.Mutex
+	data    *robotstxt.RobotsData
+	expires time.Time
+}
+
+// New создает Checker. agent — user-agent, по которому в robots.txt
+// выбирается группа правил, limiter — общий ограничитель частоты запросов парсеров.
+func New(f fetcher.Fetcher, agent string, limiter *ratelimit.Limiter) *Checker {
+	return &Checker{
+		fetcher: f,
+		agent:   agent,
+		limiter: limiter,
+		hosts:   make(map[string]*host),
+	}
+}
+
+// Allowed проверяет, разрешает ли robots.txt хоста загружать адрес rawURL.
+//
+// При первом обращении к хосту и по истечении ttl загружает robots.txt.
+// Если сервер ответил 4xx, ограничений нет, если 5xx — обход хоста запрещен
+// на время errorTTL.
+// Если файл загрузить не удалось, ограничения хоста не применяются до следующей
+// попытки и возвращается ошибка, при этом адрес считается разрешенным.
+func (c *Checker) Allowed(ctx context.Context, rawURL string) (bool, error) {
+	const op = "robots.Checker.Allowed"
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	h := c.host(strings.ToLower(u.Scheme + "://" + u.Host))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.data == nil || time.Now().After(h.expires) {
+		if err := c.fetch(ctx, u, h); err != nil {
+			return true, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return h.data.TestAgent(path, c.agent), nil
+}
+
+// fetch загружает robots.txt хоста адреса u и сохраняет его правила в h.
+// Вызывается под h.mu.
+func (c *Checker) fetch(ctx context.Context, u *url.URL, h *host) error {
+	robotsURL := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}).String()
+
+	// Запрос robots.txt тоже соблюдает паузу между запросами к хосту
+	if err := c.limiter.Wait(ctx, robotsURL); err != nil {
+		return err
+	}
+
+	resp, err := c.fetcher.Fetch(ctx, robotsURL, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := robotstxt.FromResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	h.data = data
+	h.expires = time.Now().Add(ttl)
+	if resp.StatusCode >= 500 {
+		h.expires = time.Now().Add(errorTTL)
+	}
+	// Для файла без правил или ответа 4xx группа не содержит Crawl-delay,
+	// тогда действует пауза по умолчанию
+	c.limiter.SetDelay(u.Hostname(), data.FindGroup(c.agent).CrawlDelay)
+	return nil
+}
+
+// host возвращает состояние хоста, создавая его при первом обращении.
+func (c *Checker) host(name string) *host {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h, ok := c.hosts[name]
+	if !ok {
+		h = &host{}
+		c.hosts[name] = h
+	}
+	return h
+}
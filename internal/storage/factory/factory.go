@@ -0,0 +1,89 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/terratensor/kremlin-parser/internal/config"
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/storage/jsonfile"
+	"github.com/terratensor/kremlin-parser/internal/storage/manticore"
+	"github.com/terratensor/kremlin-parser/internal/storage/memory"
+	"github.com/terratensor/kremlin-parser/internal/storage/postgres"
+	"github.com/terratensor/kremlin-parser/internal/storage/sqlite"
+)
+
+// ErrUnsupportedDriver возвращается для драйвера, который не поддерживается.
+var ErrUnsupportedDriver = errors.New("unsupported storage driver")
+
+// Пути хранилищ по умолчанию, если в конфиге не указан storage.dsn.
+const (
+	defaultSQLitePath = "./data/feed.sqlite"
+	defaultJSONLPath  = "./data/jsonl"
+)
+
+// New создает хранилище записей по секции storage конфига.
+// Хранилище создается один раз и используется всеми парсерами,
+// по завершении работы его нужно закрыть функцией Close.
+func New(ctx context.Context, cfg *config.Config) (feed.StorageInterface, error) {
+	const op = "storage.factory.New"
+
+	var (
+		store feed.StorageInterface
+		err   error
+	)
+
+	switch cfg.Storage.Driver {
+	case config.DriverManticore:
+		store, err = manticore.New(ctx, cfg.Manticore, cfg.ManticoreIndex)
+	case config.DriverSQLite:
+		dsn := cfg.Storage.DSN
+		if dsn == "" {
+			dsn = defaultSQLitePath
+		}
+		// Каталог базы создается, если DSN задан путем к файлу
+		if !strings.HasPrefix(dsn, "file:") && dsn != ":memory:" {
+			if err := os.MkdirAll(filepath.Dir(dsn), 0o755); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		store, err = sqlite.New(dsn)
+	case config.DriverPostgres:
+		if cfg.Storage.DSN == "" {
+			return nil, fmt.Errorf("%s: storage.dsn is required for postgres", op)
+		}
+		store, err = postgres.New(ctx, cfg.Storage.DSN)
+	case config.DriverJSONL:
+		dsn := cfg.Storage.DSN
+		if dsn == "" {
+			dsn = defaultJSONLPath
+		}
+		// Параметр gzip: "true" включает сжатие файлов
+		compress, _ := strconv.ParseBool(cfg.Storage.Options["gzip"])
+		store, err = jsonfile.New(dsn, compress)
+	case config.DriverMemory:
+		// Записи хранятся только до завершения программы
+		store = memory.New(nil)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedDriver, cfg.Storage.Driver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return store, nil
+}
+
+// Close закрывает хранилище, если оно держит соединение с базой или открытые файлы.
+func Close(store feed.StorageInterface) error {
+	if c, ok := store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -1,29 +1,757 @@
 package jsonfile
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
-	"github.com/terratensor/kremlin-parser/internal/entities/entry"
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/storage"
 )
 
-func checkError(message string, err error) {
+var _ feed.StorageInterface = &Storage{}
+var _ feed.Syncer = &Storage{}
+
+// Storage хранилище записей ленты в файлах JSON Lines.
+//
+// Каждая запись — одна строка json с полями feed.Entry. Файлы ведутся отдельно
+// для каждого языка и начинаются заново каждые сутки (UTC):
+//
+//	dir/ru/2006-01-02.jsonl
+//	dir/en/2006-01-02.jsonl.gz
+//
+// Файлы только дополняются: измененная запись записывается еще раз,
+// актуальной считается последняя версия, удаление записывается строкой
+// с полем "deleted": true. При открытии хранилища файлы читаются, и в памяти
+// строится индекс положения последней версии каждой записи.
+//
+// Сжатые файлы пишутся отдельными gzip потоками, каждый поток закрывается
+// в Sync, после чего файл сбрасывается на диск. Парсер вызывает Sync после
+// каждой страницы ленты.
+type Storage struct {
+	dir  string
+	gzip bool
+
+	mu      sync.Mutex
+	lastID  int64
+	byURL   map[string]location
+	byID    map[int64]string
+	byEvent map[string][]string
+	writers map[string]*writer
+}
+
+// location положение версии записи: файл, смещение gzip потока в файле
+// (для несжатых файлов 0), смещение и длина строки в распакованном потоке.
+// В meta хранятся поля записи, по которым List и Count отбирают записи
+// без чтения файлов.
+type location struct {
+	path   string
+	member int64
+	offset int64
+	length int
+	meta   feed.Entry
+}
+
+// record строка файла: версия записи или отметка об удалении.
+type record struct {
+	feed.Entry
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// writer открытый для дополнения файл языка.
+type writer struct {
+	path string
+	f    *os.File
+	// size размер файла, то есть смещение следующего gzip потока
+	size int64
+	// gz открытый gzip поток и количество записанных в него байт
+	gz      *gzip.Writer
+	counter *countingWriter
+	member  int64
+	written int64
+}
+
+// countingWriter считает байты, записанные в файл через gzip поток.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// New открывает хранилище в каталоге dir и строит индекс записей.
+// Если compress установлен, новые файлы сжимаются gzip.
+func New(dir string, compress bool) (*Storage, error) {
+	const op = "storage.jsonfile.New"
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s := &Storage{
+		dir:     dir,
+		gzip:    compress,
+		byURL:   make(map[string]location),
+		byID:    make(map[int64]string),
+		byEvent: make(map[string][]string),
+		writers: make(map[string]*writer),
+	}
+
+	paths, err := s.files()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, path := range paths {
+		if err := s.load(path); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, path, err)
+		}
+	}
+
+	return s, nil
+}
+
+// files возвращает файлы хранилища в порядке записи: по дате в имени файла.
+func (s *Storage) files() ([]string, error) {
+	var paths []string
+	for _, pattern := range []string{"*/*.jsonl", "*/*.jsonl.gz"} {
+		m, err := filepath.Glob(filepath.Join(s.dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, m...)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return filepath.Base(paths[i]) < filepath.Base(paths[j])
+	})
+	return paths, nil
+}
+
+// load читает файл и добавляет его записи в индекс.
+//
+// Если работа была прервана во время записи, в конце файла остается
+// недописанная строка или gzip поток. Они отрезаются, чтобы следующие
+// записи не склеились с ними.
+func (s *Storage) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if !strings.HasSuffix(path, ".gz") {
+		items, size, err := readLines(bufio.NewReader(f))
+		if err != nil {
+			return err
+		}
+		s.indexItems(path, 0, items)
+		return truncateTail(f, path, size)
+	}
+
+	// gzip потоки читаются по одному, чтобы знать смещение каждого в файле
+	counter := &countingReader{r: f}
+	br := bufio.NewReader(counter)
+	var member int64
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return nil
+		}
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return truncateTail(f, path, member)
+		}
+		gz.Multistream(false)
+		items, _, err := readLines(bufio.NewReader(gz))
+		if err != nil {
+			return truncateTail(f, path, member)
+		}
+		s.indexItems(path, member, items)
+		member = counter.n - int64(br.Buffered())
+	}
+}
+
+// truncateTail отрезает от файла все после size байт, если файл длиннее.
+func truncateTail(f *os.File, path string, size int64) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() <= size {
+		return nil
+	}
+	return os.Truncate(path, size)
+}
+
+// countingReader считает байты, прочитанные из файла.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// item прочитанная строка и положение её в потоке.
+type item struct {
+	record record
+	offset int64
+	length int
+}
+
+// readLines читает строки потока до конца. Возвращает прочитанные записи
+// и длину целых строк: недописанная последняя строка не учитывается.
+func readLines(r *bufio.Reader) ([]item, int64, error) {
+	var (
+		items  []item
+		offset int64
+	)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return items, offset, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, 0, fmt.Errorf("offset %d: %w", offset, err)
+		}
+		items = append(items, item{record: rec, offset: offset, length: len(line)})
+		offset += int64(len(line))
+	}
+}
+
+// indexItems добавляет в индекс записи потока member файла path.
+func (s *Storage) indexItems(path string, member int64, items []item) {
+	for i := range items {
+		rec := &items[i].record
+		if rec.ID != nil && *rec.ID > s.lastID {
+			s.lastID = *rec.ID
+		}
+		if rec.Deleted {
+			s.unindex(rec.Url)
+			continue
+		}
+		s.index(&rec.Entry, location{path: path, member: member, offset: items[i].offset, length: items[i].length})
+	}
+}
+
+// index добавляет в индекс положение последней версии записи. Вызывается под s.mu.
+func (s *Storage) index(e *feed.Entry, loc location) {
+	// Прежняя версия могла иметь другой идентификатор
+	if old, ok := s.byURL[e.Url]; ok && old.meta.ID != nil && (e.ID == nil || *old.meta.ID != *e.ID) {
+		delete(s.byID, *old.meta.ID)
+	}
+	if e.ID != nil {
+		s.byID[*e.ID] = e.Url
+		if *e.ID > s.lastID {
+			s.lastID = *e.ID
+		}
+	}
+	if e.EventKey != "" {
+		urls := s.byEvent[e.EventKey]
+		found := false
+		for _, u := range urls {
+			found = found || u == e.Url
+		}
+		if !found {
+			s.byEvent[e.EventKey] = append(urls, e.Url)
+		}
+	}
+	loc.meta = feed.Entry{ID: e.ID, Language: e.Language, ResourceID: e.ResourceID, Published: e.Published, EventKey: e.EventKey}
+	s.byURL[e.Url] = loc
+}
+
+// unindex удаляет запись с адресом url из индекса. Вызывается под s.mu.
+func (s *Storage) unindex(url string) {
+	loc, ok := s.byURL[url]
+	if !ok {
+		return
+	}
+	delete(s.byURL, url)
+	// Идентификатор мог перейти к записи с другим адресом
+	if loc.meta.ID != nil && s.byID[*loc.meta.ID] == url {
+		delete(s.byID, *loc.meta.ID)
+	}
+	key := loc.meta.EventKey
+	urls := s.byEvent[key]
+	for i, u := range urls {
+		if u == url {
+			urls = append(urls[:i], urls[i+1:]...)
+			break
+		}
+	}
+	if len(urls) == 0 {
+		delete(s.byEvent, key)
+	} else {
+		s.byEvent[key] = urls
+	}
+}
+
+func (s *Storage) FindByUrl(ctx context.Context, url string) (*feed.Entry, error) {
+	const op = "storage.jsonfile.FindByUrl"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loc, ok := s.byURL[url]
+	// Как и остальные хранилища, для отсутствующей записи возвращаем nil без ошибки
+	if !ok {
+		return nil, nil
+	}
+
+	e, err := s.read(loc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return e, nil
+}
+
+// FindByEventKey возвращает все языковые версии события с ключом key.
+func (s *Storage) FindByEventKey(ctx context.Context, key string) ([]feed.Entry, error) {
+	const op = "storage.jsonfile.FindByEventKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []feed.Entry
+	for _, url := range s.byEvent[key] {
+		loc, ok := s.byURL[url]
+		if !ok {
+			continue
+		}
+		e, err := s.read(loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		// Ключ события мог измениться в более поздней версии записи
+		if e.EventKey == key {
+			entries = append(entries, *e)
+		}
+	}
+
+	return entries, nil
+}
+
+// FindByID возвращает запись с идентификатором id или nil, если записи нет.
+func (s *Storage) FindByID(ctx context.Context, id int64) (*feed.Entry, error) {
+	const op = "storage.jsonfile.FindByID"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.byID[id]
+	if !ok {
+		return nil, nil
+	}
+
+	e, err := s.read(s.byURL[url])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return e, nil
+}
+
+// FindByUrls возвращает найденные записи с адресами urls.
+func (s *Storage) FindByUrls(ctx context.Context, urls []string) ([]feed.Entry, error) {
+	const op = "storage.jsonfile.FindByUrls"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []feed.Entry
+	for _, url := range urls {
+		loc, ok := s.byURL[url]
+		if !ok {
+			continue
+		}
+		e, err := s.read(loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		entries = append(entries, *e)
+	}
+
+	return entries, nil
+}
+
+// List возвращает страницу записей, подходящих под filter, см. feed.StorageInterface.
+// Записи отбираются по индексу, из файлов читаются только записи страницы.
+func (s *Storage) List(ctx context.Context, filter feed.Filter, after int64, limit int) ([]feed.Entry, error) {
+	const op = "storage.jsonfile.List"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []int64
+	for id, url := range s.byID {
+		if loc := s.byURL[url]; id > after && filter.Match(&loc.meta) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > limit {
+		ids = ids[:max(limit, 0)]
+	}
+
+	entries := make([]feed.Entry, 0, len(ids))
+	for _, id := range ids {
+		e, err := s.read(s.byURL[s.byID[id]])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		entries = append(entries, *e)
+	}
+
+	return entries, nil
+}
+
+// Count возвращает количество записей, подходящих под filter.
+func (s *Storage) Count(ctx context.Context, filter feed.Filter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, loc := range s.byURL {
+		if filter.Match(&loc.meta) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Delete дописывает отметку об удалении записи с идентификатором id.
+func (s *Storage) Delete(ctx context.Context, id int64) error {
+	const op = "storage.jsonfile.Delete"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.byID[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err := s.tombstone(id, url); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// tombstone дописывает отметку об удалении записи id с адресом url
+// и удаляет её из индекса. Вызывается под s.mu.
+func (s *Storage) tombstone(id int64, url string) error {
+	rec := record{
+		Entry:   feed.Entry{ID: &id, Url: url, Language: s.byURL[url].meta.Language},
+		Deleted: true,
+	}
+	if _, err := s.write(&rec); err != nil {
+		return err
+	}
+	s.unindex(url)
+	return nil
+}
+
+// Insert дописывает новую запись и возвращает присвоенный ей идентификатор.
+func (s *Storage) Insert(ctx context.Context, entry *feed.Entry) (*int64, error) {
+	const op = "storage.jsonfile.Insert"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	id := s.lastID
+
+	e := *entry
+	e.ID = &id
+	if err := s.append(&e); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &id, nil
+}
+
+// Update дописывает новую версию записи с идентификатором entry.ID.
+func (s *Storage) Update(ctx context.Context, entry *feed.Entry) error {
+	const op = "storage.jsonfile.Update"
+
+	if entry.ID == nil {
+		return fmt.Errorf("%s: entry id is empty", op)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.replace(entry); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Bulk сохраняет записи: записи без идентификатора дописывает как новые,
+// записи с идентификатором дописывает новыми версиями записи с этим
+// идентификатором. Ошибки записей возвращаются в *storage.BulkError.
+// Идентификаторы новых записей заполняются в entries.
+// Записи сбрасываются на диск одним вызовом Sync.
+func (s *Storage) Bulk(ctx context.Context, entries *[]feed.Entry) error {
+	const op = "storage.jsonfile.Bulk"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bulkErr := &storage.BulkError{Items: make(map[int]error)}
+	for i := range *entries {
+		e := &(*entries)[i]
+		if e.ID != nil {
+			if err := s.replace(e); err != nil {
+				bulkErr.Items[i] = err
+			}
+			continue
+		}
+
+		s.lastID++
+		id := s.lastID
+		e.ID = &id
+		if err := s.append(e); err != nil {
+			e.ID = nil
+			bulkErr.Items[i] = err
+		}
+	}
+	if err := s.sync(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(bulkErr.Items) > 0 {
+		return fmt.Errorf("%s: %w", op, bulkErr)
+	}
+
+	return nil
+}
+
+// replace дописывает новую версию записи с идентификатором e.ID. Если у записи
+// изменился адрес, прежний адрес отмечается удаленным. Вызывается под s.mu.
+func (s *Storage) replace(e *feed.Entry) error {
+	url, ok := s.byID[*e.ID]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if url != e.Url {
+		if err := s.tombstone(*e.ID, url); err != nil {
+			return err
+		}
+	}
+	return s.append(e)
+}
+
+// Sync закрывает открытые gzip потоки и сбрасывает файлы на диск.
+func (s *Storage) Sync() error {
+	const op = "storage.jsonfile.Sync"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.sync(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Close сбрасывает и закрывает открытые файлы.
+func (s *Storage) Close() error {
+	const op = "storage.jsonfile.Close"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for lang, w := range s.writers {
+		errs = append(errs, w.flush(), w.f.Close())
+		delete(s.writers, lang)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// sync сбрасывает открытые файлы. Вызывается под s.mu.
+func (s *Storage) sync() error {
+	for _, w := range s.writers {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// append дописывает запись в файл её языка за текущие сутки. Вызывается под s.mu.
+func (s *Storage) append(e *feed.Entry) error {
+	loc, err := s.write(&record{Entry: *e})
 	if err != nil {
-		log.Fatal(message, err)
+		return err
 	}
+
+	s.index(e, loc)
+	return nil
 }
 
-func WriteJsonFile(entries entry.Entries, outputPath string) {
+// write дописывает строку в файл языка записи и возвращает её положение.
+// Вызывается под s.mu.
+func (s *Storage) write(rec *record) (location, error) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return location{}, err
+	}
+	line = append(line, '\n')
+
+	w, err := s.writer(rec.Language)
+	if err != nil {
+		return location{}, err
+	}
+
+	loc := location{path: w.path, length: len(line)}
+	if s.gzip {
+		if w.gz == nil {
+			w.counter = &countingWriter{w: w.f}
+			w.gz = gzip.NewWriter(w.counter)
+			w.member = w.size
+			w.written = 0
+		}
+		loc.member, loc.offset = w.member, w.written
+		if _, err := w.gz.Write(line); err != nil {
+			return location{}, err
+		}
+		w.written += int64(len(line))
+	} else {
+		loc.offset = w.size
+		n, err := w.f.Write(line)
+		w.size += int64(n)
+		if err != nil {
+			return location{}, err
+		}
+	}
 
-	// Create file
-	file, err := os.Create(outputPath)
-	checkError("Cannot create file", err)
-	defer file.Close()
+	return loc, nil
+}
 
-	aJson, err := json.MarshalIndent(entries, "", "\t")
+// writer возвращает файл языка lang за текущие сутки, при смене суток
+// закрывает файл предыдущих. Вызывается под s.mu.
+func (s *Storage) writer(lang string) (*writer, error) {
+	if lang == "" {
+		lang = "unknown"
+	}
+
+	name := time.Now().UTC().Format("2006-01-02") + ".jsonl"
+	if s.gzip {
+		name += ".gz"
+	}
+	path := filepath.Join(s.dir, lang, name)
+
+	w, ok := s.writers[lang]
+	if ok && w.path == path {
+		return w, nil
+	}
+	if ok {
+		if err := errors.Join(w.flush(), w.f.Close()); err != nil {
+			return nil, err
+		}
+		delete(s.writers, lang)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	w = &writer{path: path, f: f, size: info.Size()}
+	s.writers[lang] = w
+	return w, nil
+}
+
+// flush закрывает открытый gzip поток и сбрасывает файл на диск.
+func (w *writer) flush() error {
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			return err
+		}
+		w.size += w.counter.n
+		w.gz, w.counter = nil, nil
+	}
+	return w.f.Sync()
+}
+
+// read читает версию записи по её положению. Вызывается под s.mu.
+func (s *Storage) read(loc location) (*feed.Entry, error) {
+	// Запись еще в открытом gzip потоке, поток нужно закрыть, чтобы её прочитать
+	for _, w := range s.writers {
+		if w.path == loc.path && w.gz != nil && w.member == loc.member {
+			if err := w.flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	f, err := os.Open(loc.path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	defer f.Close()
+
+	line := make([]byte, loc.length)
+	if strings.HasSuffix(loc.path, ".gz") {
+		if _, err := f.Seek(loc.member, io.SeekStart); err != nil {
+			return nil, err
+		}
+		gz, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return nil, err
+		}
+		gz.Multistream(false)
+		if _, err := io.CopyN(io.Discard, gz, loc.offset); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(gz, line); err != nil {
+			return nil, err
+		}
+	} else if _, err := f.ReadAt(line, loc.offset); err != nil {
+		return nil, err
+	}
+
+	var e feed.Entry
+	if err := json.Unmarshal(bytes.TrimSpace(line), &e); err != nil {
+		return nil, err
 	}
-	_, err = file.Write(aJson)
-	checkError("Cannot write to the file", err)
+	return &e, nil
 }
@@ -0,0 +1,73 @@
+package jsonfile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/storage"
+	"github.com/terratensor/kremlin-parser/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		compress bool
+	}{
+		{"plain", false},
+		{"gzip", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := New(t.TempDir(), tc.compress)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := storagetest.Run(context.Background(), s); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestReplaceReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := New(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := s.Insert(ctx, &feed.Entry{Language: "ru", Url: "https://example.test/a", Title: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := int64(1000)
+	entries := []feed.Entry{
+		{ID: id, Language: "ru", Url: "https://example.test/b", Title: "b"},
+		{ID: &missing, Language: "ru", Url: "https://example.test/missing"},
+	}
+	var bulkErr *storage.BulkError
+	if err := s.Bulk(ctx, &entries); !errors.As(err, &bulkErr) || !errors.Is(bulkErr.Items[1], storage.ErrNotFound) || bulkErr.Items[0] != nil {
+		t.Fatalf("Bulk() error = %v, want not found error for item 1 only", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = New(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if e, err := s.FindByUrl(ctx, "https://example.test/a"); err != nil || e != nil {
+		t.Errorf("FindByUrl(a) = %v, %v, want nil, nil", e, err)
+	}
+	e, err := s.FindByID(ctx, *id)
+	if err != nil || e == nil || e.Url != "https://example.test/b" {
+		t.Errorf("FindByID(%d) = %v, %v, want entry b", *id, e, err)
+	}
+}
@@ -0,0 +1,121 @@
+package manticore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	openapiclient "github.com/manticoresoftware/manticoresearch-go"
+	"github.com/terratensor/kremlin-parser/internal/config"
+)
+
+// maxReadyBackoff наибольшая пауза между проверками готовности мантикоры при запуске.
+const maxReadyBackoff = 10 * time.Second
+
+// newAPIClient создает клиент HTTP API мантикоры по настройкам подключения cfg.
+func newAPIClient(cfg config.Manticore) *openapiclient.APIClient {
+	configuration := openapiclient.NewConfiguration()
+	configuration.Servers = openapiclient.ServerConfigurations{
+		{URL: fmt.Sprintf("%s://%s", cfg.Scheme, net.JoinHostPort(cfg.Host, fmt.Sprint(cfg.Port)))},
+	}
+	configuration.HTTPClient = &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &transport{
+			next:        http.DefaultTransport,
+			username:    cfg.Username,
+			password:    cfg.Password,
+			maxAttempts: cfg.MaxAttempts,
+			backoff:     cfg.RetryBackoff,
+		},
+	}
+
+	return openapiclient.NewAPIClient(configuration)
+}
+
+// transport добавляет к запросам basic авторизацию и повторяет запросы,
+// которые не дошли до мантикоры: при ошибке соединения и ответах 502, 503, 504.
+// Запросы, на которые мантикора не ответила по таймауту, не повторяются,
+// так как запись могла быть сохранена.
+type transport struct {
+	next        http.RoundTripper
+	username    string
+	password    string
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.backoff
+	for attempt := 1; ; attempt++ {
+		r := req.Clone(req.Context())
+		if req.Body != nil && req.GetBody != nil && attempt > 1 {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+		if t.username != "" {
+			r.SetBasicAuth(t.username, t.password)
+		}
+
+		resp, err := t.next.RoundTrip(r)
+		retry := false
+		switch {
+		case err != nil:
+			retry = isDialError(err)
+		case resp.StatusCode == http.StatusBadGateway,
+			resp.StatusCode == http.StatusServiceUnavailable,
+			resp.StatusCode == http.StatusGatewayTimeout:
+			retry = true
+		}
+		// Повторить запрос с телом можно, только если тело можно прочитать заново
+		if !retry || attempt >= t.maxAttempts || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isDialError сообщает, что соединение с мантикорой не установлено.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// waitReady ждет, пока мантикора начнет отвечать на запросы, но не дольше timeout.
+// Пауза между проверками удваивается, начиная с backoff.
+func waitReady(ctx context.Context, apiClient *openapiclient.APIClient, timeout, backoff time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	for {
+		_, _, err := apiClient.UtilsAPI.Sql(ctx).Body("show status like 'uptime'").Execute()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("manticore is not ready after %s: %w", timeout, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxReadyBackoff)
+	}
+}
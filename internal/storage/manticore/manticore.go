@@ -1,18 +1,25 @@
 package manticore
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	openapiclient "github.com/manticoresoftware/manticoresearch-go"
+	"github.com/terratensor/kremlin-parser/internal/config"
 	"github.com/terratensor/kremlin-parser/internal/entities/feed"
-	"log"
-	"os"
+	"github.com/terratensor/kremlin-parser/internal/storage"
+	"hash/fnv"
+	"net/http"
 	"strconv"
 	"time"
 )
 
-var _ feed.StorageInterface = &Client{}
+var (
+	_ feed.StorageInterface = &Client{}
+	_ feed.IDAssigner       = &Client{}
+)
 
 type Response struct {
 	Took     int  `json:"took"`
@@ -40,16 +47,53 @@ type Response struct {
 }
 
 type DBEntry struct {
-	Language   string `json:"language"`
-	Title      string `json:"title"`
-	Url        string `json:"url"`
-	Updated    int64  `json:"updated"`
-	Published  int64  `json:"published"`
-	Summary    string `json:"summary"`
-	Content    string `json:"content"`
-	Author     string `json:"author"`
-	Number     string `json:"number"`
-	ResourceID int    `json:"resource_id"`
+	Language       string            `json:"language"`
+	Title          string            `json:"title"`
+	Url            string            `json:"url"`
+	Updated        int64             `json:"updated"`
+	Published      int64             `json:"published"`
+	Summary        string            `json:"summary"`
+	Content        string            `json:"content"`
+	Author         string            `json:"author"`
+	Number         string            `json:"number"`
+	ResourceID     int               `json:"resource_id"`
+	Categories     []int64           `json:"categories"`
+	Tags           []int64           `json:"tags"`
+	Persons        []int64           `json:"persons"`
+	Regions        []int64           `json:"regions"`
+	Taxonomy       Taxonomy          `json:"taxonomy"`
+	Attachments    []feed.Attachment `json:"attachments"`
+	EventKey       string            `json:"event_key"`
+	CounterpartUrl string            `json:"counterpart_url"`
+	CounterpartID  int64             `json:"counterpart_id"`
+}
+
+// Taxonomy названия значений таксономии записи. Мантикора поддерживает только
+// числовые множественные атрибуты (MVA), поэтому в атрибутах categories, tags,
+// persons и regions хранятся идентификаторы feed.TermID, по которым строятся
+// фасеты, а сами названия хранятся в json атрибуте taxonomy.
+type Taxonomy struct {
+	Categories []string `json:"categories"`
+	Tags       []string `json:"tags"`
+	Persons    []string `json:"persons"`
+	Regions    []string `json:"regions"`
+}
+
+// columns колонки таблицы, добавленные после её первоначального создания.
+// При запуске отсутствующие колонки добавляются в существующую таблицу.
+var columns = []struct {
+	name string
+	typ  string
+}{
+	{"categories", "multi64"},
+	{"tags", "multi64"},
+	{"persons", "multi64"},
+	{"regions", "multi64"},
+	{"taxonomy", "json"},
+	{"attachments", "json"},
+	{"event_key", "string"},
+	{"counterpart_url", "string"},
+	{"counterpart_id", "bigint"},
 }
 
 type Client struct {
@@ -62,76 +106,145 @@ func NewDBEntry(entry *feed.Entry) *DBEntry {
 		Language:   entry.Language,
 		Title:      entry.Title,
 		Url:        entry.Url,
-		Updated:    entry.Updated.Unix(),
-		Published:  entry.Published.Unix(),
+		Updated:    unixTime(entry.Updated),
+		Published:  unixTime(entry.Published),
 		Summary:    entry.Summary,
 		Content:    entry.Content,
 		Author:     entry.Author,
 		Number:     entry.Number,
 		ResourceID: entry.ResourceID,
+		Categories: feed.TermIDs(entry.Categories),
+		Tags:       feed.TermIDs(entry.Tags),
+		Persons:    feed.TermIDs(entry.Persons),
+		Regions:    feed.TermIDs(entry.Regions),
+		Taxonomy: Taxonomy{
+			Categories: entry.Categories,
+			Tags:       entry.Tags,
+			Persons:    entry.Persons,
+			Regions:    entry.Regions,
+		},
+		Attachments:    entry.Attachments,
+		EventKey:       entry.EventKey,
+		CounterpartUrl: entry.CounterpartUrl,
+	}
+	if entry.CounterpartID != nil {
+		dbe.CounterpartID = *entry.CounterpartID
 	}
 
 	return dbe
 }
 
-func New(tbl string) (*Client, error) {
-	// Initialize ApiClient
-	configuration := openapiclient.NewConfiguration()
-	apiClient := openapiclient.NewAPIClient(configuration)
+// unixTime возвращает время в секундах unix, для записи без даты — 0.
+func unixTime(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
 
-	query := fmt.Sprintf(`show tables like '%v'`, tbl)
+// New подключается к мантикоре по настройкам cfg, ждет её готовности
+// и создает таблицу tbl или добавляет в существующую недостающие колонки.
+func New(ctx context.Context, cfg config.Manticore, tbl string) (*Client, error) {
+	const op = "storage.manticore.New"
 
-	// Проверяем существует ли таблица tbl, если нет, то создаем
-	resp, _, _ := apiClient.UtilsAPI.Sql(context.Background()).Body(query).Execute()
-	data := resp[0]["data"].([]interface{})
+	apiClient := newAPIClient(cfg)
 
-	if len(data) > 0 {
-		myMap := data[0].(map[string]interface{})
-		indexValue := myMap["Index"]
+	if err := waitReady(ctx, apiClient, cfg.StartupTimeout, cfg.RetryBackoff); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
-		if indexValue != tbl {
-			err := createTable(apiClient, tbl)
-			if err != nil {
-				return nil, err
-			}
-		}
+	exists, err := tableExists(ctx, apiClient, tbl)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if exists {
+		err = migrateTable(ctx, apiClient, tbl)
 	} else {
-		err := createTable(apiClient, tbl)
-		if err != nil {
-			return nil, err
-		}
+		err = createTable(ctx, apiClient, tbl)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return &Client{apiClient: apiClient, Index: tbl}, nil
 }
 
-func createTable(apiClient *openapiclient.APIClient, tbl string) error {
+// tableExists проверяет, существует ли таблица tbl.
+func tableExists(ctx context.Context, apiClient *openapiclient.APIClient, tbl string) (bool, error) {
+	resp, _, err := apiClient.UtilsAPI.Sql(ctx).Body(fmt.Sprintf(`show tables like '%v'`, tbl)).Execute()
+	if err != nil {
+		return false, fmt.Errorf("show tables: %w", err)
+	}
+	if len(resp) == 0 {
+		return false, fmt.Errorf("show tables: empty response")
+	}
+	data, _ := resp[0]["data"].([]interface{})
+
+	// В шаблоне like символ _ совпадает с любым символом, поэтому имя сверяется.
+	// В новых версиях мантикоры колонка с именем таблицы называется Table
+	for _, row := range data {
+		fields, _ := row.(map[string]interface{})
+		if fields["Index"] == tbl || fields["Table"] == tbl {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func createTable(ctx context.Context, apiClient *openapiclient.APIClient, tbl string) error {
 
-	query := fmt.Sprintf(`create table %v(language string, url string, title text, summary text, content text, published timestamp, updated timestamp, author string, number string, resource_id int) engine='columnar' min_infix_len='3' index_exact_words='1' morphology='stem_en, stem_ru, libstemmer_de, libstemmer_fr, libstemmer_es, libstemmer_pt' html_remove_elements = 'style, script' html_strip = '1' index_sp='1'`, tbl)
+	query := fmt.Sprintf(`create table %v(language string, url string, title text, summary text, content text, published timestamp, updated timestamp, author string, number string, resource_id int, categories multi64, tags multi64, persons multi64, regions multi64, taxonomy json, attachments json, event_key string, counterpart_url string, counterpart_id bigint) engine='columnar' min_infix_len='3' index_exact_words='1' morphology='stem_en, stem_ru, libstemmer_de, libstemmer_fr, libstemmer_es, libstemmer_pt' html_remove_elements = 'style, script' html_strip = '1' index_sp='1'`, tbl)
 
-	sqlRequest := apiClient.UtilsAPI.Sql(context.Background()).Body(query)
-	_, _, err := apiClient.UtilsAPI.SqlExecute(sqlRequest)
+	_, _, err := apiClient.UtilsAPI.Sql(ctx).Body(query).Execute()
 	if err != nil {
-		return err
+		return fmt.Errorf("create table: %w", err)
 	}
 
 	return nil
 }
 
-func (c *Client) Insert(ctx context.Context, entry *feed.Entry) (*int64, error) {
-
-	dbe := NewDBEntry(entry)
+// migrateTable добавляет в существующую таблицу tbl недостающие колонки из columns.
+func migrateTable(ctx context.Context, apiClient *openapiclient.APIClient, tbl string) error {
+	const op = "storage.manticore.migrateTable"
 
-	//marshal into JSON buffer
-	buffer, err := json.Marshal(dbe)
+	resp, _, err := apiClient.UtilsAPI.Sql(ctx).Body(fmt.Sprintf("describe %v", tbl)).Execute()
 	if err != nil {
-		return nil, fmt.Errorf("error marshaling JSON: %v\n", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(resp) == 0 {
+		return fmt.Errorf("%s: empty response", op)
 	}
+	data, _ := resp[0]["data"].([]interface{})
 
-	var doc map[string]interface{}
-	err = json.Unmarshal(buffer, &doc)
+	existing := make(map[string]bool, len(data))
+	for _, row := range data {
+		if field, ok := row.(map[string]interface{}); ok {
+			existing[fmt.Sprint(field["Field"])] = true
+		}
+	}
+
+	for _, col := range columns {
+		if existing[col.name] {
+			continue
+		}
+		query := fmt.Sprintf("alter table %v add column %v %v", tbl, col.name, col.typ)
+		_, _, err := apiClient.UtilsAPI.Sql(ctx).Body(query).Execute()
+		if err != nil {
+			return fmt.Errorf("%s: add column %v: %w", op, col.name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) Insert(ctx context.Context, entry *feed.Entry) (*int64, error) {
+	const op = "storage.manticore.Insert"
+
+	doc, err := newDoc(entry)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling buffer: %v\n", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	idr := openapiclient.InsertDocumentRequest{
@@ -139,29 +252,37 @@ func (c *Client) Insert(ctx context.Context, entry *feed.Entry) (*int64, error)
 		Doc:   doc,
 	}
 
-	resp, r, err := c.apiClient.IndexAPI.Insert(ctx).InsertDocumentRequest(idr).Execute()
+	_, r, err := c.apiClient.IndexAPI.Insert(ctx).InsertDocumentRequest(idr).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
+	// Идентификатор читается из тела ответа без потери точности
+	var resp struct {
+		ID json.Number `json:"_id"`
+	}
+	if err := decodeNumbers(r, &resp); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	id, err := resp.ID.Int64()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Full HTTP response: %v\n", r)
-		return nil, fmt.Errorf("Error when calling `IndexAPI.Insert``: %v\n", err)
+		return nil, fmt.Errorf("%s: invalid id %q", op, resp.ID)
 	}
 
-	return resp.Id, nil
+	return &id, nil
 }
 
+// Update заменяет запись с идентификатором entry.ID.
 func (c *Client) Update(ctx context.Context, entry *feed.Entry) error {
-	dbe := NewDBEntry(entry)
+	const op = "storage.manticore.Update"
 
-	//marshal into JSON buffer
-	buffer, err := json.Marshal(dbe)
-	if err != nil {
-		return fmt.Errorf("error marshaling JSON: %v\n", err)
+	if entry.ID == nil {
+		return fmt.Errorf("%s: entry id is empty", op)
 	}
 
-	var doc map[string]interface{}
-	err = json.Unmarshal(buffer, &doc)
+	doc, err := newDoc(entry)
 	if err != nil {
-		return fmt.Errorf("error unmarshaling buffer: %v\n", err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	idr := openapiclient.InsertDocumentRequest{
@@ -170,132 +291,411 @@ func (c *Client) Update(ctx context.Context, entry *feed.Entry) error {
 		Doc:   doc,
 	}
 
-	_, r, err := c.apiClient.IndexAPI.Replace(ctx).InsertDocumentRequest(idr).Execute()
+	if _, _, err := c.apiClient.IndexAPI.Replace(ctx).InsertDocumentRequest(idr).Execute(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
 
+// newDoc возвращает документ записи для запросов insert и replace.
+func newDoc(entry *feed.Entry) (map[string]interface{}, error) {
+	buffer, err := json.Marshal(NewDBEntry(entry))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Full HTTP response: %v\n", r)
-		return fmt.Errorf("Error when calling `IndexAPI.Replace``: %v\n", err)
+		return nil, err
 	}
 
-	log.Printf("Success `IndexAPI.Replace`: %v\n", r)
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buffer, &doc); err != nil {
+		return nil, err
+	}
 
-	return nil
+	return doc, nil
 }
 
+// Bulk сохраняет записи одним запросом к bulk API в формате NDJSON.
+//
+// Записи без идентификатора добавляются (insert) с идентификатором,
+// который назначает мантикора, записи с идентификатором заменяют документ
+// с этим идентификатором (replace). Записи по адресам не ищутся.
+// Идентификаторы новых записей заполняются в entries. Результат каждой
+// операции сопоставляется с записью по порядку, если часть записей
+// не сохранена, возвращается *storage.BulkError.
 func (c *Client) Bulk(ctx context.Context, entries *[]feed.Entry) error {
+	const op = "storage.manticore.Bulk"
 
-	var serializedEntries string
-	for _, e := range *entries {
-		eJSON, err := json.Marshal(e)
+	if len(*entries) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	for i := range *entries {
+		e := &(*entries)[i]
+
+		action := bulkAction{Index: c.Index, ID: e.ID, Doc: NewDBEntry(e)}
+		line := map[string]bulkAction{"insert": action}
+		if action.ID != nil {
+			line = map[string]bulkAction{"replace": action}
+		}
+		data, err := json.Marshal(line)
 		if err != nil {
-			return fmt.Errorf("error marshaling JSON: %v\n", err)
+			return fmt.Errorf("%s: %s: %w", op, e.Url, err)
 		}
-		serializedEntries += string(eJSON) + "\n"
+		body.Write(data)
+		body.WriteByte('\n')
 	}
 
-	fmt.Println(serializedEntries)
-
-	_, r, err := c.apiClient.IndexAPI.Bulk(ctx).Body(serializedEntries).Execute()
+	_, r, err := c.apiClient.IndexAPI.Bulk(ctx).Body(body.String()).Execute()
+	// При ошибке части операций мантикора может ответить кодом ошибки,
+	// результаты операций все равно читаются из тела ответа
+	if r == nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	var resp bulkResponse
+	if decodeErr := decodeNumbers(r, &resp); decodeErr != nil || len(resp.Items) == 0 {
+		if err == nil {
+			err = fmt.Errorf("unexpected bulk response: %v", decodeErr)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Full HTTP response: %v\n", r)
-		return fmt.Errorf("Error when calling `IndexAPI.Insert``: %v\n", err)
+	bulkErr := &storage.BulkError{Items: make(map[int]error)}
+	for i := range *entries {
+		if i >= len(resp.Items) {
+			bulkErr.Items[i] = errors.New("not processed")
+			continue
+		}
+		id, err := resp.Items[i].result()
+		if err != nil {
+			bulkErr.Items[i] = err
+			continue
+		}
+		(*entries)[i].ID = &id
+	}
+	if len(bulkErr.Items) > 0 {
+		return fmt.Errorf("%s: %w", op, bulkErr)
 	}
-	// response from `Insert`: SuccessResponse
-	fmt.Fprintf(os.Stdout, "Success Response from `IndexAPI.Insert`: %v\n", r)
 
 	return nil
 }
 
+// AssignID возвращает идентификатор новой записи: хеш FNV-1a её адреса без
+// старшего бита. Документ с таким идентификатором сохраняется операцией replace,
+// поэтому повторное сохранение новой записи после сбоя не создает дубликат.
+func (c *Client) AssignID(e *feed.Entry) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(e.Url))
+	// Нулевой идентификатор мантикора заменяет своим
+	if id := int64(h.Sum64() &^ (1 << 63)); id != 0 {
+		return id
+	}
+	return 1
+}
+
+// bulkAction операция insert или replace запроса к bulk API.
+type bulkAction struct {
+	Index string   `json:"index"`
+	ID    *int64   `json:"id,omitempty"`
+	Doc   *DBEntry `json:"doc"`
+}
+
+// bulkResponse ответ bulk API.
+type bulkResponse struct {
+	Items  []bulkItem `json:"items"`
+	Errors bool       `json:"errors"`
+}
+
+// bulkItem результат операции с ключом по её типу: {"insert": {...}} или {"replace": {...}}.
+type bulkItem map[string]struct {
+	ID     json.Number     `json:"_id"`
+	Status int             `json:"status"`
+	Result string          `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// result возвращает идентификатор сохраненной записи или ошибку операции.
+func (item bulkItem) result() (int64, error) {
+	for action, res := range item {
+		if len(res.Error) > 0 && string(res.Error) != "null" {
+			return 0, fmt.Errorf("%s: %s", action, res.Error)
+		}
+		if res.Status >= 300 {
+			return 0, fmt.Errorf("%s: status %d", action, res.Status)
+		}
+		id, err := res.ID.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("%s: invalid id %q", action, res.ID)
+		}
+		return id, nil
+	}
+	return 0, errors.New("empty result")
+}
+
+// decodeNumbers читает тело ответа r в v. Числа читаются как json.Number:
+// идентификаторы мантикоры не помещаются в float64 без потери точности.
+// Клиент openapi подменяет тело прочитанного ответа копией, поэтому его можно прочитать еще раз.
+func decodeNumbers(r *http.Response, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
 func (c *Client) FindByUrl(ctx context.Context, url string) (*feed.Entry, error) {
-	// response from `Search`: SearchRequest
+	const op = "storage.manticore.FindByUrl"
+
 	searchRequest := *openapiclient.NewSearchRequest(c.Index)
+	searchRequest.SetQuery(map[string]interface{}{"equals": map[string]interface{}{"url": url}})
 
-	// Perform a search
-	// Пример для запроса фильтра по url
-	filter := map[string]interface{}{"url": url}
-	query := map[string]interface{}{"equals": filter}
+	entries, err := c.search(ctx, searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// Если слайс пустой, значит нет совпадений
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	return &entries[0], nil
+}
+
+// FindByEventKey возвращает все языковые версии события с ключом key.
+func (c *Client) FindByEventKey(ctx context.Context, key string) ([]feed.Entry, error) {
+	const op = "storage.manticore.FindByEventKey"
+
+	searchRequest := *openapiclient.NewSearchRequest(c.Index)
+	searchRequest.SetQuery(map[string]interface{}{"equals": map[string]interface{}{"event_key": key}})
+
+	entries, err := c.search(ctx, searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// FindByID возвращает запись с идентификатором id или nil, если записи нет.
+func (c *Client) FindByID(ctx context.Context, id int64) (*feed.Entry, error) {
+	const op = "storage.manticore.FindByID"
+
+	searchRequest := *openapiclient.NewSearchRequest(c.Index)
+	searchRequest.SetQuery(map[string]interface{}{"equals": map[string]interface{}{"id": id}})
+
+	entries, err := c.search(ctx, searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	return &entries[0], nil
+}
+
+// FindByUrls возвращает найденные записи с адресами urls.
+// Адреса передаются частями, чтобы не превысить ограничение на размер выборки.
+func (c *Client) FindByUrls(ctx context.Context, urls []string) ([]feed.Entry, error) {
+	const op = "storage.manticore.FindByUrls"
+	const chunkSize = 100
+
+	var entries []feed.Entry
+	for start := 0; start < len(urls); start += chunkSize {
+		chunk := urls[start:min(start+chunkSize, len(urls))]
 
+		searchRequest := *openapiclient.NewSearchRequest(c.Index)
+		searchRequest.SetQuery(map[string]interface{}{"in": map[string]interface{}{"url": chunk}})
+		searchRequest.SetLimit(int32(len(chunk)))
+
+		found, err := c.search(ctx, searchRequest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		entries = append(entries, found...)
+	}
+
+	return entries, nil
+}
+
+// List возвращает страницу записей, подходящих под filter, см. feed.StorageInterface.
+func (c *Client) List(ctx context.Context, filter feed.Filter, after int64, limit int) ([]feed.Entry, error) {
+	const op = "storage.manticore.List"
+
+	must := append(filterQuery(filter), map[string]interface{}{
+		"range": map[string]interface{}{"id": map[string]interface{}{"gt": after}},
+	})
+
+	searchRequest := *openapiclient.NewSearchRequest(c.Index)
+	searchRequest.SetQuery(map[string]interface{}{"bool": map[string]interface{}{"must": must}})
+	searchRequest.SetSort([]map[string]interface{}{{"id": "asc"}})
+	searchRequest.SetLimit(int32(limit))
+	// Размер выборки ограничен max_matches, по умолчанию 1000
+	if limit > 1000 {
+		searchRequest.SetMaxMatches(int32(limit))
+	}
+
+	entries, err := c.search(ctx, searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// Count возвращает количество записей, подходящих под filter.
+func (c *Client) Count(ctx context.Context, filter feed.Filter) (int64, error) {
+	const op = "storage.manticore.Count"
+
+	query := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if must := filterQuery(filter); len(must) > 0 {
+		query = map[string]interface{}{"bool": map[string]interface{}{"must": must}}
+	}
+
+	searchRequest := *openapiclient.NewSearchRequest(c.Index)
 	searchRequest.SetQuery(query)
-	resp, r, err := c.apiClient.SearchAPI.Search(context.Background()).SearchRequest(searchRequest).Execute()
+	searchRequest.SetLimit(0)
+
+	resp, _, err := c.apiClient.SearchAPI.Search(ctx).SearchRequest(searchRequest).Execute()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	hits := resp.GetHits()
+
+	return int64(hits.GetTotal()), nil
+}
 
+// Delete удаляет запись с идентификатором id.
+func (c *Client) Delete(ctx context.Context, id int64) error {
+	const op = "storage.manticore.Delete"
+
+	req := openapiclient.DeleteDocumentRequest{
+		Index: c.Index,
+		Id:    &id,
+	}
+	resp, _, err := c.apiClient.IndexAPI.Delete(ctx).DeleteDocumentRequest(req).Execute()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Full HTTP response: %v\n", r)
-		return nil, fmt.Errorf("Error when calling `SearchAPI.Search.Equals``: %v\n", err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	if resp.GetResult() == "not found" || (resp.Deleted != nil && *resp.Deleted == 0) {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
 
-	id, err := getEntryID(resp)
+// search выполняет поисковый запрос и преобразует найденные документы в записи.
+func (c *Client) search(ctx context.Context, searchRequest openapiclient.SearchRequest) ([]feed.Entry, error) {
+	_, r, err := c.apiClient.SearchAPI.Search(ctx).SearchRequest(searchRequest).Execute()
 	if err != nil {
 		return nil, err
 	}
 
-	dbe := makeDBEntry(resp)
-	if dbe == nil {
-		return nil, nil
+	var resp openapiclient.SearchResponse
+	if err := decodeNumbers(r, &resp); err != nil {
+		return nil, err
 	}
-	//log.Printf("id %d\n", *id)
-	//log.Printf("dbe %v\n", dbe)
-	updated := time.Unix(dbe.Updated, 0)
-	published := time.Unix(dbe.Published, 0)
 
-	ent := &feed.Entry{
-		ID:         id,
-		Language:   dbe.Language,
-		Title:      dbe.Title,
-		Url:        dbe.Url,
-		Updated:    &updated,
-		Published:  &published,
-		Summary:    dbe.Summary,
-		Content:    dbe.Content,
-		Author:     dbe.Author,
-		Number:     dbe.Number,
-		ResourceID: dbe.ResourceID,
+	hits := resp.GetHits()
+	entries := make([]feed.Entry, 0, len(hits.Hits))
+	for _, hit := range hits.Hits {
+		e, err := makeEntry(hit)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
 	}
 
-	return ent, nil
+	return entries, nil
 }
 
-func makeDBEntry(resp *openapiclient.SearchResponse) *DBEntry {
-	var hits []map[string]interface{}
-	hits = resp.Hits.Hits
+// filterQuery возвращает условия фильтра для секции must запроса bool.
+// Даты публикации хранятся в таблице в секундах unix.
+func filterQuery(f feed.Filter) []map[string]interface{} {
+	var must []map[string]interface{}
+	if f.Language != "" {
+		must = append(must, map[string]interface{}{"equals": map[string]interface{}{"language": f.Language}})
+	}
+	if f.ResourceID != 0 {
+		must = append(must, map[string]interface{}{"equals": map[string]interface{}{"resource_id": f.ResourceID}})
+	}
+	if f.Since != nil || f.Until != nil {
+		published := map[string]interface{}{}
+		if f.Since != nil {
+			published["gte"] = f.Since.Unix()
+		}
+		if f.Until != nil {
+			published["lt"] = f.Until.Unix()
+		}
+		must = append(must, map[string]interface{}{"range": map[string]interface{}{"published": published}})
+	}
+	return must
+}
 
-	// Если слайс Hits пустой (0) значит нет совпадений
-	if len(hits) == 0 {
-		return nil
+// makeEntry преобразует результат поиска в feed.Entry.
+func makeEntry(hit map[string]interface{}) (*feed.Entry, error) {
+	id, err := getEntryID(hit)
+	if err != nil {
+		return nil, err
+	}
+
+	dbe, err := makeDBEntry(hit)
+	if err != nil {
+		return nil, err
 	}
 
-	hit := hits[0]
+	ent := &feed.Entry{
+		ID:             id,
+		Language:       dbe.Language,
+		Title:          dbe.Title,
+		Url:            dbe.Url,
+		Summary:        dbe.Summary,
+		Content:        dbe.Content,
+		Author:         dbe.Author,
+		Number:         dbe.Number,
+		ResourceID:     dbe.ResourceID,
+		Categories:     dbe.Taxonomy.Categories,
+		Tags:           dbe.Taxonomy.Tags,
+		Persons:        dbe.Taxonomy.Persons,
+		Regions:        dbe.Taxonomy.Regions,
+		Attachments:    dbe.Attachments,
+		EventKey:       dbe.EventKey,
+		CounterpartUrl: dbe.CounterpartUrl,
+	}
+	if dbe.CounterpartID != 0 {
+		ent.CounterpartID = &dbe.CounterpartID
+	}
+	// Запись без даты сохранена с нулевым временем, см. unixTime
+	if dbe.Updated != 0 {
+		updated := time.Unix(dbe.Updated, 0)
+		ent.Updated = &updated
+	}
+	if dbe.Published != 0 {
+		published := time.Unix(dbe.Published, 0)
+		ent.Published = &published
+	}
 
+	return ent, nil
+}
+
+func makeDBEntry(hit map[string]interface{}) (*DBEntry, error) {
 	sr := hit["_source"]
 	jsonData, err := json.Marshal(sr)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling JSON: %v\n", err)
+	}
 
 	var dbe DBEntry
 	err = json.Unmarshal(jsonData, &dbe)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("error unmarshaling JSON: %v\n", err)
 	}
 
-	return &dbe
+	return &dbe, nil
 }
 
-func getEntryID(resp *openapiclient.SearchResponse) (*int64, error) {
-	var hits []map[string]interface{}
-	var _id interface{}
-
-	hits = resp.Hits.Hits
-
-	// Если слайс Hits пустой (0) значит нет совпадений
-	if len(hits) == 0 {
-		return nil, nil
-	}
-
-	hit := hits[0]
-
-	_id = hit["_id"]
-	id, err := strconv.ParseInt(_id.(string), 10, 64)
-	//log.Printf("id %d\n", id)
+func getEntryID(hit map[string]interface{}) (*int64, error) {
+	_id := hit["_id"]
+	id, err := strconv.ParseInt(fmt.Sprint(_id), 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse ID to int64: %v\n", resp)
+		return nil, fmt.Errorf("Failed to parse ID to int64: %v\n", _id)
 	}
 
 	return &id, nil
@@ -0,0 +1,48 @@
+package manticore
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/terratensor/kremlin-parser/internal/config"
+	"github.com/terratensor/kremlin-parser/internal/storage/storagetest"
+)
+
+// Проверка запускается, только если в MANTICORE_TEST_URL указан адрес
+// тестовой мантикоры, например http://127.0.0.1:9308. Записи проверки
+// сохраняются в таблицу storagetest и удаляются по её завершении.
+func TestStorage(t *testing.T) {
+	addr := os.Getenv("MANTICORE_TEST_URL")
+	if addr == "" {
+		t.Skip("MANTICORE_TEST_URL is not set")
+	}
+
+	var cfg config.Manticore
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Scheme, cfg.Host = u.Scheme, u.Hostname()
+	if p := u.Port(); p != "" {
+		if cfg.Port, err = strconv.Atoi(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	s, err := New(ctx, cfg, "storagetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storagetest.Run(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,506 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/storage"
+)
+
+var _ feed.StorageInterface = &Storage{}
+
+// Операции журнала изменений.
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Change запись журнала изменений: операция и сохраненная (для удаления —
+// удаленная) версия записи.
+type Change struct {
+	Op    string
+	Entry feed.Entry
+	At    time.Time
+}
+
+// Storage хранилище записей в памяти с индексами по адресу и идентификатору
+// и журналом изменений. Безопасно для одновременного использования.
+//
+// Если задано базовое хранилище base, записи, которых нет в памяти, ищутся
+// в нем, а изменения сохраняются только в памяти. Так можно посмотреть,
+// что парсер добавил бы или изменил в настоящем хранилище, не изменяя его.
+// Новые записи в этом случае получают идентификаторы больше наибольшего
+// идентификатора базового хранилища, поэтому List отдает их после записей
+// базового хранилища в порядке добавления.
+type Storage struct {
+	base feed.StorageInterface
+	// baseMu защищает baseReady: наибольший идентификатор базового хранилища
+	// ищется один раз, перед добавлением первой записи
+	baseMu    sync.Mutex
+	baseReady bool
+
+	mu     sync.RWMutex
+	lastID int64
+	byID   map[int64]*feed.Entry
+	byURL  map[string]int64
+	// deleted адреса удаленных записей, которые больше не читаются из base
+	deleted map[string]bool
+	changes []Change
+}
+
+// New создает пустое хранилище. base может быть nil.
+func New(base feed.StorageInterface) *Storage {
+	return &Storage{
+		base:    base,
+		byID:    make(map[int64]*feed.Entry),
+		byURL:   make(map[string]int64),
+		deleted: make(map[string]bool),
+	}
+}
+
+// Changes возвращает копию журнала изменений в порядке операций.
+func (s *Storage) Changes() []Change {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	changes := make([]Change, len(s.changes))
+	copy(changes, s.changes)
+	return changes
+}
+
+// Entries возвращает все записи хранилища в памяти.
+func (s *Storage) Entries() []feed.Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]feed.Entry, 0, len(s.byID))
+	for _, e := range s.byID {
+		entries = append(entries, *e)
+	}
+	return entries
+}
+
+func (s *Storage) FindByUrl(ctx context.Context, url string) (*feed.Entry, error) {
+	const op = "storage.memory.FindByUrl"
+
+	s.mu.RLock()
+	if id, ok := s.byURL[url]; ok {
+		e := *s.byID[id]
+		s.mu.RUnlock()
+		return &e, nil
+	}
+	hidden := s.hidden(url)
+	s.mu.RUnlock()
+
+	if s.base == nil || hidden {
+		return nil, nil
+	}
+	e, err := s.base.FindByUrl(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return e, nil
+}
+
+// FindByID возвращает запись с идентификатором id или nil, если записи нет.
+func (s *Storage) FindByID(ctx context.Context, id int64) (*feed.Entry, error) {
+	const op = "storage.memory.FindByID"
+
+	s.mu.RLock()
+	if e, ok := s.byID[id]; ok {
+		c := *e
+		s.mu.RUnlock()
+		return &c, nil
+	}
+	s.mu.RUnlock()
+
+	if s.base == nil {
+		return nil, nil
+	}
+	e, err := s.base.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if e != nil && s.hidden(e.Url) {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// FindByUrls возвращает найденные записи с адресами urls.
+func (s *Storage) FindByUrls(ctx context.Context, urls []string) ([]feed.Entry, error) {
+	const op = "storage.memory.FindByUrls"
+
+	var (
+		entries []feed.Entry
+		rest    []string
+	)
+	s.mu.RLock()
+	for _, url := range urls {
+		if id, ok := s.byURL[url]; ok {
+			entries = append(entries, *s.byID[id])
+		} else if !s.hidden(url) {
+			rest = append(rest, url)
+		}
+	}
+	s.mu.RUnlock()
+
+	if s.base == nil || len(rest) == 0 {
+		return entries, nil
+	}
+	base, err := s.base.FindByUrls(ctx, rest)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return append(entries, base...), nil
+}
+
+// FindByEventKey возвращает все языковые версии события с ключом key.
+// Версии из памяти заменяют версии с тем же адресом из базового хранилища.
+func (s *Storage) FindByEventKey(ctx context.Context, key string) ([]feed.Entry, error) {
+	const op = "storage.memory.FindByEventKey"
+
+	var entries []feed.Entry
+	if s.base != nil {
+		base, err := s.base.FindByEventKey(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		entries = base
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := entries[:0]
+	for _, e := range entries {
+		if !s.hidden(e.Url) {
+			result = append(result, e)
+		}
+	}
+	for _, e := range s.byID {
+		if e.EventKey == key {
+			result = append(result, *e)
+		}
+	}
+
+	return result, nil
+}
+
+// List возвращает страницу записей, подходящих под filter, см. feed.StorageInterface.
+// Записи базового хранилища, замененные или удаленные в памяти, пропускаются.
+func (s *Storage) List(ctx context.Context, filter feed.Filter, after int64, limit int) ([]feed.Entry, error) {
+	const op = "storage.memory.List"
+
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	var entries []feed.Entry
+
+	// Из базового хранилища набираем limit видимых записей, затем добавляем
+	// записи из памяти после курсора и оставляем limit записей с меньшими
+	// идентификаторами. Скрытые записи базового хранилища пропускаются
+	if s.base != nil {
+		cursor, found := after, 0
+		for found < limit {
+			page, err := s.base.List(ctx, filter, cursor, limit)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			s.mu.RLock()
+			for _, e := range page {
+				if !s.hidden(e.Url) {
+					entries = append(entries, e)
+					found++
+				}
+			}
+			s.mu.RUnlock()
+			if len(page) < limit {
+				break
+			}
+			cursor = *page[len(page)-1].ID
+		}
+	}
+
+	s.mu.RLock()
+	for id, e := range s.byID {
+		if id > after && filter.Match(e) {
+			entries = append(entries, *e)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return *entries[i].ID < *entries[j].ID
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// Count возвращает количество записей, подходящих под filter.
+func (s *Storage) Count(ctx context.Context, filter feed.Filter) (int64, error) {
+	const op = "storage.memory.Count"
+
+	var n int64
+	if s.base != nil {
+		count, err := s.base.Count(ctx, filter)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		n = count
+
+		// Версии записей в базовом хранилище, замененные или удаленные в памяти, не считаются
+		s.mu.RLock()
+		urls := make([]string, 0, len(s.byURL)+len(s.deleted))
+		for url := range s.byURL {
+			urls = append(urls, url)
+		}
+		for url := range s.deleted {
+			if _, ok := s.byURL[url]; !ok {
+				urls = append(urls, url)
+			}
+		}
+		s.mu.RUnlock()
+
+		if len(urls) > 0 {
+			shadowed, err := s.base.FindByUrls(ctx, urls)
+			if err != nil {
+				return 0, fmt.Errorf("%s: %w", op, err)
+			}
+			for i := range shadowed {
+				if filter.Match(&shadowed[i]) {
+					n--
+				}
+			}
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, e := range s.byID {
+		if filter.Match(e) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Insert добавляет запись и возвращает присвоенный ей идентификатор.
+func (s *Storage) Insert(ctx context.Context, entry *feed.Entry) (*int64, error) {
+	const op = "storage.memory.Insert"
+
+	existing, err := s.FindByUrl(ctx, entry.Url)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.initIDs(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byURL[entry.Url]; ok || existing != nil {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+	}
+
+	id := s.nextID()
+	s.save(OpInsert, entry, id)
+
+	return &id, nil
+}
+
+// Update заменяет запись с идентификатором entry.ID. Запись базового
+// хранилища при первом изменении копируется в память.
+func (s *Storage) Update(ctx context.Context, entry *feed.Entry) error {
+	const op = "storage.memory.Update"
+
+	if entry.ID == nil {
+		return fmt.Errorf("%s: entry id is empty", op)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[*entry.ID]; !ok && s.base == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+	s.save(OpUpdate, entry, *entry.ID)
+
+	return nil
+}
+
+// Bulk сохраняет записи: записи без идентификатора добавляет, записи
+// с идентификатором заменяют запись с этим идентификатором. Запись базового
+// хранилища при первом изменении копируется в память.
+// Идентификаторы новых записей заполняются в entries.
+func (s *Storage) Bulk(ctx context.Context, entries *[]feed.Entry) error {
+	const op = "storage.memory.Bulk"
+
+	if err := s.initIDs(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bulkErr := &storage.BulkError{Items: make(map[int]error)}
+	for i := range *entries {
+		e := &(*entries)[i]
+		if e.ID != nil {
+			// Записи базового хранилища не проверяются, чтобы не искать их
+			if _, ok := s.byID[*e.ID]; !ok && s.base == nil {
+				bulkErr.Items[i] = storage.ErrNotFound
+				continue
+			}
+			s.save(OpUpdate, e, *e.ID)
+			continue
+		}
+		id := s.nextID()
+		s.save(OpInsert, e, id)
+		e.ID = &id
+	}
+	if len(bulkErr.Items) > 0 {
+		return fmt.Errorf("%s: %w", op, bulkErr)
+	}
+
+	return nil
+}
+
+// Delete удаляет запись с идентификатором id. Запись базового хранилища
+// только скрывается, само базовое хранилище не изменяется.
+func (s *Storage) Delete(ctx context.Context, id int64) error {
+	const op = "storage.memory.Delete"
+
+	s.mu.Lock()
+	if e, ok := s.byID[id]; ok {
+		s.remove(e)
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+
+	if s.base == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	e, err := s.FindByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if e == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(e)
+
+	return nil
+}
+
+// hidden сообщает, что запись базового хранилища с адресом url заменена
+// или удалена в памяти. Вызывается под s.mu.
+func (s *Storage) hidden(url string) bool {
+	_, ok := s.byURL[url]
+	return ok || s.deleted[url]
+}
+
+// nextID возвращает идентификатор для новой записи. Вызывается под s.mu
+// после initIDs.
+func (s *Storage) nextID() int64 {
+	s.lastID++
+	return s.lastID
+}
+
+// initIDs продолжает нумерацию новых записей с наибольшего идентификатора
+// базового хранилища. Поиск выполняется один раз.
+func (s *Storage) initIDs(ctx context.Context) error {
+	if s.base == nil {
+		return nil
+	}
+
+	s.baseMu.Lock()
+	defer s.baseMu.Unlock()
+	if s.baseReady {
+		return nil
+	}
+
+	id, err := maxID(ctx, s.base)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if id > s.lastID {
+		s.lastID = id
+	}
+	s.mu.Unlock()
+	s.baseReady = true
+
+	return nil
+}
+
+// maxID находит наибольший идентификатор записи в store двоичным поиском
+// по курсору List, не читая все записи: нужно не больше 64 запросов по одной
+// записи. Для пустого хранилища возвращает 0.
+func maxID(ctx context.Context, store feed.StorageInterface) (int64, error) {
+	// Наибольший идентификатор лежит в отрезке [lo, hi]
+	lo, hi := int64(0), int64(math.MaxInt64)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		page, err := store.List(ctx, feed.Filter{}, mid, 1)
+		if err != nil {
+			return 0, err
+		}
+		if len(page) == 0 {
+			hi = mid
+			continue
+		}
+		lo = *page[0].ID
+	}
+	return lo, nil
+}
+
+// save сохраняет копию записи с идентификатором id и добавляет её в журнал.
+// Вызывается под s.mu.
+func (s *Storage) save(op string, entry *feed.Entry, id int64) {
+	e := *entry
+	e.ID = &id
+
+	// Если адрес записи изменился, старый адрес больше не указывает на неё
+	if old, ok := s.byID[id]; ok && old.Url != e.Url {
+		delete(s.byURL, old.Url)
+	}
+	s.byID[id] = &e
+	s.byURL[e.Url] = id
+
+	s.changes = append(s.changes, Change{Op: op, Entry: e, At: time.Now()})
+}
+
+// remove удаляет запись и добавляет удаление в журнал. Вызывается под s.mu.
+func (s *Storage) remove(e *feed.Entry) {
+	if id, ok := s.byURL[e.Url]; ok && id == *e.ID {
+		delete(s.byURL, e.Url)
+	}
+	delete(s.byID, *e.ID)
+	if s.base != nil {
+		s.deleted[e.Url] = true
+	}
+
+	s.changes = append(s.changes, Change{Op: OpDelete, Entry: *e, At: time.Now()})
+}
@@ -0,0 +1,20 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/terratensor/kremlin-parser/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	if err := storagetest.Run(context.Background(), New(nil)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStorageWithBase(t *testing.T) {
+	if err := storagetest.Run(context.Background(), New(New(nil))); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS entry (
+    id              BIGSERIAL PRIMARY KEY,
+    language        TEXT        NOT NULL DEFAULT '',
+    url             TEXT        NOT NULL,
+    title           TEXT        NOT NULL DEFAULT '',
+    summary         TEXT        NOT NULL DEFAULT '',
+    content         TEXT        NOT NULL DEFAULT '',
+    updated         TIMESTAMPTZ,
+    published       TIMESTAMPTZ,
+    author          TEXT        NOT NULL DEFAULT '',
+    number          TEXT        NOT NULL DEFAULT '',
+    resource_id     INTEGER     NOT NULL DEFAULT 0,
+    categories      TEXT[]      NOT NULL DEFAULT '{}',
+    tags            TEXT[]      NOT NULL DEFAULT '{}',
+    persons         TEXT[]      NOT NULL DEFAULT '{}',
+    regions         TEXT[]      NOT NULL DEFAULT '{}',
+    attachments     JSONB       NOT NULL DEFAULT '[]',
+    event_key       TEXT        NOT NULL DEFAULT '',
+    counterpart_url TEXT        NOT NULL DEFAULT '',
+    counterpart_id  BIGINT,
+    CONSTRAINT entry_url_key UNIQUE (url)
+);
+
+CREATE INDEX IF NOT EXISTS entry_published_idx ON entry (published);
+CREATE INDEX IF NOT EXISTS entry_event_key_idx ON entry (event_key);
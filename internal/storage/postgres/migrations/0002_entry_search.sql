@@ -0,0 +1,17 @@
+-- Полнотекстовый поиск: заголовок важнее аннотации, аннотация важнее текста.
+-- Записи на русском и английском индексируются в обеих конфигурациях,
+-- так как в русских материалах встречаются английские названия и наоборот.
+ALTER TABLE entry
+    ADD COLUMN IF NOT EXISTS search_ru tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', title), 'A') ||
+        setweight(to_tsvector('russian', summary), 'B') ||
+        setweight(to_tsvector('russian', content), 'C')
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_en tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', title), 'A') ||
+        setweight(to_tsvector('english', summary), 'B') ||
+        setweight(to_tsvector('english', content), 'C')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS entry_search_ru_idx ON entry USING GIN (search_ru);
+CREATE INDEX IF NOT EXISTS entry_search_en_idx ON entry USING GIN (search_en);
@@ -0,0 +1,478 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/storage"
+)
+
+var _ feed.StorageInterface = &Storage{}
+
+// migrations схема базы, файлы применяются по порядку имен.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Storage хранилище записей ленты в PostgreSQL.
+//
+// Адрес записи уникален, Insert, Update и Bulk сохраняют записи запросом
+// INSERT ... ON CONFLICT (url), поэтому повторное сохранение записи
+// с тем же адресом заменяет её. Для полнотекстового поиска в таблице есть
+// вычисляемые колонки tsvector в конфигурациях russian и english.
+type Storage struct {
+	pool *pgxpool.Pool
+}
+
+// entryColumns колонки записи в порядке, в котором их читает scanEntry.
+const entryColumns = `id, language, url, title, summary, content, updated, published,
+	author, number, resource_id, categories, tags, persons, regions,
+	attachments, event_key, counterpart_url, counterpart_id`
+
+// upsertQuery сохраняет запись, заменяя запись с тем же адресом.
+const upsertQuery = `INSERT INTO entry (
+	language, url, title, summary, content, updated, published,
+	author, number, resource_id, categories, tags, persons, regions,
+	attachments, event_key, counterpart_url, counterpart_id
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+ON CONFLICT (url) DO UPDATE SET
+	language = excluded.language, title = excluded.title, summary = excluded.summary,
+	content = excluded.content, updated = excluded.updated, published = excluded.published,
+	author = excluded.author, number = excluded.number, resource_id = excluded.resource_id,
+	categories = excluded.categories, tags = excluded.tags, persons = excluded.persons,
+	regions = excluded.regions, attachments = excluded.attachments, event_key = excluded.event_key,
+	counterpart_url = excluded.counterpart_url, counterpart_id = excluded.counterpart_id
+RETURNING id`
+
+// insertQuery добавляет запись, параметры — entryArgs.
+const insertQuery = `INSERT INTO entry (
+	language, url, title, summary, content, updated, published,
+	author, number, resource_id, categories, tags, persons, regions,
+	attachments, event_key, counterpart_url, counterpart_id
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+RETURNING id`
+
+// updateQuery заменяет запись с идентификатором $19, остальные параметры — entryArgs.
+const updateQuery = `UPDATE entry SET
+	language = $1, url = $2, title = $3, summary = $4, content = $5, updated = $6, published = $7,
+	author = $8, number = $9, resource_id = $10, categories = $11, tags = $12, persons = $13,
+	regions = $14, attachments = $15, event_key = $16, counterpart_url = $17, counterpart_id = $18
+WHERE id = $19`
+
+// New подключается к базе по строке подключения dsn и применяет миграции схемы.
+func New(ctx context.Context, dsn string) (*Storage, error) {
+	const op = "storage.postgres.New"
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := migrate(ctx, pool); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{pool: pool}, nil
+}
+
+// migrate применяет миграции, которые еще не отмечены в таблице schema_migrations.
+// Каждая миграция выполняется в отдельной транзакции. Одновременный запуск
+// нескольких парсеров упорядочивается advisory блокировкой.
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	// Произвольный ключ блокировки миграций парсера
+	const lockKey = 7_420_515
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+
+		var applied bool
+		err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		query, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(query)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+// Close закрывает соединения с базой.
+func (s *Storage) Close() error {
+	s.pool.Close()
+	return nil
+}
+
+func (s *Storage) FindByUrl(ctx context.Context, url string) (*feed.Entry, error) {
+	const op = "storage.postgres.FindByUrl"
+
+	row := s.pool.QueryRow(ctx, `SELECT `+entryColumns+` FROM entry WHERE url = $1`, url)
+	e, err := scanEntry(row)
+	// Как и остальные хранилища, для отсутствующей записи возвращаем nil без ошибки
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return e, nil
+}
+
+// FindByEventKey возвращает все языковые версии события с ключом key.
+func (s *Storage) FindByEventKey(ctx context.Context, key string) ([]feed.Entry, error) {
+	const op = "storage.postgres.FindByEventKey"
+
+	rows, err := s.pool.Query(ctx, `SELECT `+entryColumns+` FROM entry WHERE event_key = $1`, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	entries, err := scanEntries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// FindByID возвращает запись с идентификатором id или nil, если записи нет.
+func (s *Storage) FindByID(ctx context.Context, id int64) (*feed.Entry, error) {
+	const op = "storage.postgres.FindByID"
+
+	row := s.pool.QueryRow(ctx, `SELECT `+entryColumns+` FROM entry WHERE id = $1`, id)
+	e, err := scanEntry(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return e, nil
+}
+
+// FindByUrls возвращает найденные записи с адресами urls.
+func (s *Storage) FindByUrls(ctx context.Context, urls []string) ([]feed.Entry, error) {
+	const op = "storage.postgres.FindByUrls"
+
+	rows, err := s.pool.Query(ctx, `SELECT `+entryColumns+` FROM entry WHERE url = ANY($1)`, urls)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	entries, err := scanEntries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// List возвращает страницу записей, подходящих под filter, см. feed.StorageInterface.
+func (s *Storage) List(ctx context.Context, filter feed.Filter, after int64, limit int) ([]feed.Entry, error) {
+	const op = "storage.postgres.List"
+
+	where, args := filterWhere(filter)
+	args = append(args, after, limit)
+	where = append(where, fmt.Sprintf("id > $%d", len(args)-1))
+
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`SELECT %s FROM entry
+		WHERE %s
+		ORDER BY id
+		LIMIT $%d`, entryColumns, strings.Join(where, " AND "), len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	entries, err := scanEntries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// Count возвращает количество записей, подходящих под filter.
+func (s *Storage) Count(ctx context.Context, filter feed.Filter) (int64, error) {
+	const op = "storage.postgres.Count"
+
+	where, args := filterWhere(filter)
+	query := `SELECT count(*) FROM entry`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+
+	var n int64
+	if err := s.pool.QueryRow(ctx, query, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// Delete удаляет запись с идентификатором id.
+func (s *Storage) Delete(ctx context.Context, id int64) error {
+	const op = "storage.postgres.Delete"
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM entry WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Search ищет записи по запросу в синтаксисе websearch_to_tsquery в заголовке,
+// аннотации и тексте. Язык запроса lang ("ru" или "en") выбирает конфигурацию
+// полнотекстового поиска. Записи возвращаются в порядке релевантности.
+func (s *Storage) Search(ctx context.Context, query string, lang string, limit int) ([]feed.Entry, error) {
+	const op = "storage.postgres.Search"
+
+	column, config := "search_ru", "russian"
+	if lang == "en" {
+		column, config = "search_en", "english"
+	}
+
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`SELECT %s FROM entry, websearch_to_tsquery('%s', $1) q
+		WHERE %s @@ q
+		ORDER BY ts_rank(%s, q) DESC
+		LIMIT $2`, entryColumns, config, column, column), query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	entries, err := scanEntries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// Insert сохраняет запись и возвращает её идентификатор.
+// Если запись с тем же адресом уже есть, она заменяется.
+func (s *Storage) Insert(ctx context.Context, entry *feed.Entry) (*int64, error) {
+	const op = "storage.postgres.Insert"
+
+	var id int64
+	if err := s.pool.QueryRow(ctx, upsertQuery, entryArgs(entry)...).Scan(&id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &id, nil
+}
+
+// Update заменяет запись с идентификатором entry.ID.
+func (s *Storage) Update(ctx context.Context, entry *feed.Entry) error {
+	const op = "storage.postgres.Update"
+
+	if entry.ID == nil {
+		return fmt.Errorf("%s: entry id is empty", op)
+	}
+	if err := update(ctx, s.pool, entry); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Bulk сохраняет записи в одной транзакции: записи без идентификатора
+// добавляет, записи с идентификатором заменяют запись с этим идентификатором.
+// Каждая запись сохраняется во вложенной транзакции (точке сохранения),
+// поэтому ошибка одной записи не отменяет остальные: ошибки записей
+// возвращаются в *storage.BulkError. Идентификаторы новых записей заполняются в entries.
+func (s *Storage) Bulk(ctx context.Context, entries *[]feed.Entry) error {
+	const op = "storage.postgres.Bulk"
+
+	bulkErr := &storage.BulkError{Items: make(map[int]error)}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for i := range *entries {
+			e := &(*entries)[i]
+			err := pgx.BeginFunc(ctx, tx, func(item pgx.Tx) error {
+				if e.ID != nil {
+					return update(ctx, item, e)
+				}
+				var id int64
+				if err := item.QueryRow(ctx, insertQuery, entryArgs(e)...).Scan(&id); err != nil {
+					return err
+				}
+				e.ID = &id
+				return nil
+			})
+			if err != nil {
+				// Ошибки соединения и отмена контекста прерывают весь пакет
+				if ctx.Err() != nil || tx.Conn().IsClosed() {
+					return err
+				}
+				bulkErr.Items[i] = err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(bulkErr.Items) > 0 {
+		return fmt.Errorf("%s: %w", op, bulkErr)
+	}
+
+	return nil
+}
+
+// querier общий интерфейс пула соединений и транзакции.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// update заменяет запись с идентификатором e.ID.
+func update(ctx context.Context, db querier, e *feed.Entry) error {
+	tag, err := db.Exec(ctx, updateQuery, append(entryArgs(e), *e.ID)...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// entryArgs возвращает параметры upsertQuery, insertQuery и updateQuery.
+// Пустые списки передаются пустыми массивами, так как колонки таблицы не допускают NULL.
+func entryArgs(e *feed.Entry) []any {
+	attachments := e.Attachments
+	if attachments == nil {
+		attachments = []feed.Attachment{}
+	}
+
+	return []any{
+		e.Language, e.Url, e.Title, e.Summary, e.Content, e.Updated, e.Published,
+		e.Author, e.Number, e.ResourceID, nonNil(e.Categories), nonNil(e.Tags),
+		nonNil(e.Persons), nonNil(e.Regions), attachments, e.EventKey,
+		e.CounterpartUrl, e.CounterpartID,
+	}
+}
+
+// filterWhere возвращает условия запроса с параметрами $1, $2, ... и их значения для filter.
+func filterWhere(f feed.Filter) ([]string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Language != "" {
+		add("language = $%d", f.Language)
+	}
+	if f.ResourceID != 0 {
+		add("resource_id = $%d", f.ResourceID)
+	}
+	if f.Since != nil {
+		add("published >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		add("published < $%d", *f.Until)
+	}
+	return where, args
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// scanEntry читает запись, выбранную колонками entryColumns.
+func scanEntry(row pgx.Row) (*feed.Entry, error) {
+	var (
+		e  feed.Entry
+		id int64
+	)
+	err := row.Scan(
+		&id, &e.Language, &e.Url, &e.Title, &e.Summary, &e.Content, &e.Updated, &e.Published,
+		&e.Author, &e.Number, &e.ResourceID, &e.Categories, &e.Tags, &e.Persons, &e.Regions,
+		&e.Attachments, &e.EventKey, &e.CounterpartUrl, &e.CounterpartID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	e.ID = &id
+
+	return &e, nil
+}
+
+// scanEntries читает все записи из rows и закрывает rows.
+func scanEntries(rows pgx.Rows) ([]feed.Entry, error) {
+	defer rows.Close()
+
+	var entries []feed.Entry
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
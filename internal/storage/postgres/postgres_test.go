@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/terratensor/kremlin-parser/internal/storage/storagetest"
+)
+
+// Проверка запускается, только если в POSTGRES_TEST_DSN указана тестовая база.
+func TestStorage(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	ctx := context.Background()
+	s, err := New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := storagetest.Run(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !sqlite_fts5
+
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearchWithoutFTS5(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "feed.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.Search(context.Background(), "бюджет", 10); !errors.Is(err, ErrNoSearch) {
+		t.Errorf("Search() error = %v, want %v", err, ErrNoSearch)
+	}
+}
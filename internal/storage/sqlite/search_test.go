@@ -0,0 +1,69 @@
+//go:build sqlite_fts5
+
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(filepath.Join(t.TempDir(), "feed.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	entries := []feed.Entry{
+		{Url: "https://example.test/a", Title: "Совещание по экономическим вопросам", Content: "<p>Обсуждали бюджет</p>"},
+		{Url: "https://example.test/b", Title: "Телефонный разговор", Summary: "Обсуждали бюджет и экономику"},
+		{Url: "https://example.test/c", Title: "Meeting on the economy", Content: "<p>Budget issues</p>"},
+	}
+	if err := s.Bulk(ctx, &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(query string, want ...string) {
+		t.Helper()
+		found, err := s.Search(ctx, query, 10)
+		if err != nil {
+			t.Fatalf("Search(%q): %v", query, err)
+		}
+		got := make([]string, 0, len(found))
+		for _, e := range found {
+			got = append(got, e.Url)
+		}
+		sort.Strings(got)
+		if len(got) != len(want) {
+			t.Fatalf("Search(%q) = %v, want %v", query, got, want)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("Search(%q) = %v, want %v", query, got, want)
+			}
+		}
+	}
+
+	search("бюджет", "https://example.test/a", "https://example.test/b")
+	search("budget", "https://example.test/c")
+	search("совещание", "https://example.test/a")
+	search("разговор OR meeting", "https://example.test/b", "https://example.test/c")
+	search("парад")
+
+	// Индекс следует за изменением и удалением записей
+	entries[1].Summary = "Обсуждали парад"
+	if err := s.Update(ctx, &entries[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, *entries[2].ID); err != nil {
+		t.Fatal(err)
+	}
+	search("бюджет", "https://example.test/a")
+	search("парад", "https://example.test/b")
+	search("budget")
+}
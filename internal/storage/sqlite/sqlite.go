@@ -1,46 +1,635 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/storage"
 )
 
-// Storage структура для объекта Storage
+var _ feed.StorageInterface = &Storage{}
+
+// Storage хранилище записей ленты в базе SQLite.
+//
+// Записи хранятся в таблице entry, значения таксономии и вложения — в json колонках.
+// Для полнотекстового поиска по заголовку, аннотации и тексту записи
+// используется внешний индекс FTS5 entry_fts, который поддерживается триггерами.
+// FTS5 входит в драйвер только при сборке с тегом sqlite_fts5:
+//
+//	go build -tags sqlite_fts5 ./cmd/kremlin-parser
+//
+// Без него хранилище работает без индекса, а Search возвращает ErrNoSearch.
 type Storage struct {
 	db *sql.DB
+	// search индекс entry_fts доступен
+	search bool
 }
 
+// ErrNoSearch возвращается из Search, если драйвер собран без FTS5.
+var ErrNoSearch = errors.New("full-text search requires build with -tags sqlite_fts5")
+
+// columns колонки таблицы, добавленные после её первоначального создания.
+// При запуске отсутствующие колонки добавляются в существующую таблицу.
+var columns = []struct {
+	name string
+	typ  string
+}{
+	{"language", "TEXT NOT NULL DEFAULT ''"},
+	{"author", "TEXT NOT NULL DEFAULT ''"},
+	{"number", "TEXT NOT NULL DEFAULT ''"},
+	{"resource_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"categories", "TEXT"},
+	{"tags", "TEXT"},
+	{"persons", "TEXT"},
+	{"regions", "TEXT"},
+	{"attachments", "TEXT"},
+	{"event_key", "TEXT NOT NULL DEFAULT ''"},
+	{"counterpart_url", "TEXT NOT NULL DEFAULT ''"},
+	{"counterpart_id", "INTEGER"},
+}
+
+// entryColumns колонки записи в порядке, в котором их читает scanEntry.
+const entryColumns = `e.id, e.language, e.url, e.title, e.summary, e.content, e.updated, e.published,
+	e.author, e.number, e.resource_id, e.categories, e.tags, e.persons, e.regions,
+	e.attachments, e.event_key, e.counterpart_url, e.counterpart_id`
+
 // New конструктор объекта Storage
 func New(storagePath string) (*Storage, error) {
-	const op = "storage.sqlite.NewStorage"
+	const op = "storage.sqlite.New"
 
 	db, err := sql.Open("sqlite3", storagePath) // Подключаемся к БД
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	// SQLite допускает только одну пишущую транзакцию, парсеры лент
+	// работают параллельно, поэтому запросы выполняются по очереди
+	db.SetMaxOpenConns(1)
 
 	// Создаем таблицу если её ещё нет
-	stmt, err := db.Prepare(`
+	_, err = db.Exec(`
 	CREATE TABLE IF NOT EXISTS entry(
 	    id INTEGER PRIMARY KEY,
-	    url TEXT NOT NULL UNIQUE,	    
+	    url TEXT NOT NULL UNIQUE,
 	    title TEXT NOT NULL,
 	    summary TEXT NOT NULL,
 	    content TEXT NOT NULL,
-	    updated TIMESTAMP ,
-		published TIMESTAMP
+	    updated TIMESTAMP,
+	    published TIMESTAMP
+	);
+`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := migrate(db); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	search, err := createSearchIndex(db)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{db: db, search: search}, nil
+}
+
+// migrate добавляет в таблицу entry недостающие колонки из columns и создает индексы.
+func migrate(db *sql.DB) error {
+	rows, err := db.Query(`PRAGMA table_info(entry)`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid       int
+			name, typ string
+			notNull   bool
+			dflt      sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, col := range columns {
+		if existing[col.name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE entry ADD COLUMN %s %s`, col.name, col.typ)); err != nil {
+			return fmt.Errorf("add column %s: %w", col.name, err)
+		}
+	}
+
+	_, err = db.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_entry_published ON entry(published);
+	CREATE INDEX IF NOT EXISTS idx_entry_event_key ON entry(event_key);
+`)
+	return err
+}
+
+// createSearchIndex создает индекс FTS5 по заголовку, аннотации и тексту записей
+// и триггеры, которые синхронизируют его с таблицей entry. Если индекс создается
+// для уже заполненной таблицы, он строится по существующим записям.
+// Возвращает false, если драйвер собран без FTS5 и индекс не создан. Базу
+// с индексом без FTS5 открыть нельзя: триггеры индекса не дадут записывать в неё.
+func createSearchIndex(db *sql.DB) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'entry_fts'`).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	var fts5 bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil {
+		return false, err
+	}
+	if !fts5 {
+		if count > 0 {
+			return false, fmt.Errorf("database has full-text index entry_fts, build with -tags sqlite_fts5")
+		}
+		return false, nil
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	_, err = db.Exec(`
+	CREATE VIRTUAL TABLE entry_fts USING fts5(
+	    title, summary, content,
+	    content='entry', content_rowid='id',
+	    tokenize='unicode61 remove_diacritics 2'
 	);
-	CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
+	CREATE TRIGGER IF NOT EXISTS entry_ai AFTER INSERT ON entry BEGIN
+	    INSERT INTO entry_fts(rowid, title, summary, content) VALUES (new.id, new.title, new.summary, new.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS entry_ad AFTER DELETE ON entry BEGIN
+	    INSERT INTO entry_fts(entry_fts, rowid, title, summary, content) VALUES ('delete', old.id, old.title, old.summary, old.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS entry_au AFTER UPDATE ON entry BEGIN
+	    INSERT INTO entry_fts(entry_fts, rowid, title, summary, content) VALUES ('delete', old.id, old.title, old.summary, old.content);
+	    INSERT INTO entry_fts(rowid, title, summary, content) VALUES (new.id, new.title, new.summary, new.content);
+	END;
+	INSERT INTO entry_fts(entry_fts) VALUES ('rebuild');
 `)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Close закрывает соединение с базой.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+func (s *Storage) FindByUrl(ctx context.Context, url string) (*feed.Entry, error) {
+	const op = "storage.sqlite.FindByUrl"
+
+	row := s.db.QueryRowContext(ctx, `SELECT `+entryColumns+` FROM entry e WHERE e.url = ?`, url)
+	e, err := scanEntry(row)
+	// Как и остальные хранилища, для отсутствующей записи возвращаем nil без ошибки
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return e, nil
+}
+
+// FindByEventKey возвращает все языковые версии события с ключом key.
+func (s *Storage) FindByEventKey(ctx context.Context, key string) ([]feed.Entry, error) {
+	const op = "storage.sqlite.FindByEventKey"
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+entryColumns+` FROM entry e WHERE e.event_key = ?`, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	entries, err := scanEntries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// FindByID возвращает запись с идентификатором id или nil, если записи нет.
+func (s *Storage) FindByID(ctx context.Context, id int64) (*feed.Entry, error) {
+	const op = "storage.sqlite.FindByID"
+
+	row := s.db.QueryRowContext(ctx, `SELECT `+entryColumns+` FROM entry e WHERE e.id = ?`, id)
+	e, err := scanEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return e, nil
+}
+
+// FindByUrls возвращает найденные записи с адресами urls.
+// Адреса передаются частями, чтобы не превысить ограничение SQLite на число параметров запроса.
+func (s *Storage) FindByUrls(ctx context.Context, urls []string) ([]feed.Entry, error) {
+	const op = "storage.sqlite.FindByUrls"
+	const chunkSize = 500
+
+	var entries []feed.Entry
+	for start := 0; start < len(urls); start += chunkSize {
+		chunk := urls[start:min(start+chunkSize, len(urls))]
+
+		args := make([]any, len(chunk))
+		for i, url := range chunk {
+			args[i] = url
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", ")
+
+		rows, err := s.db.QueryContext(ctx, `SELECT `+entryColumns+` FROM entry e WHERE e.url IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		found, err := scanEntries(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		entries = append(entries, found...)
+	}
+
+	return entries, nil
+}
+
+// List возвращает страницу записей, подходящих под filter, см. feed.StorageInterface.
+func (s *Storage) List(ctx context.Context, filter feed.Filter, after int64, limit int) ([]feed.Entry, error) {
+	const op = "storage.sqlite.List"
+
+	where, args := filterWhere(filter)
+	where = append(where, "e.id > ?")
+	args = append(args, after, limit)
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+entryColumns+` FROM entry e
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY e.id
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	entries, err := scanEntries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// Count возвращает количество записей, подходящих под filter.
+func (s *Storage) Count(ctx context.Context, filter feed.Filter) (int64, error) {
+	const op = "storage.sqlite.Count"
+
+	where, args := filterWhere(filter)
+	query := `SELECT count(*) FROM entry e`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+
+	var n int64
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// Delete удаляет запись с идентификатором id.
+func (s *Storage) Delete(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.Delete"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM entry WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Search ищет записи по запросу в синтаксисе FTS5 в заголовке, аннотации
+// и тексте записи. Записи возвращаются в порядке релевантности.
+// Если драйвер собран без FTS5, возвращается ErrNoSearch.
+func (s *Storage) Search(ctx context.Context, query string, limit int) ([]feed.Entry, error) {
+	const op = "storage.sqlite.Search"
+
+	if !s.search {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoSearch)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+entryColumns+` FROM entry_fts
+		JOIN entry e ON e.id = entry_fts.rowid
+		WHERE entry_fts MATCH ?
+		ORDER BY entry_fts.rank
+		LIMIT ?`, query, limit)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = stmt.Exec()
+	entries, err := scanEntries(rows)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{db: db}, nil
+	return entries, nil
+}
+
+func (s *Storage) Insert(ctx context.Context, entry *feed.Entry) (*int64, error) {
+	const op = "storage.sqlite.Insert"
+
+	args, err := entryArgs(entry)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := insert(ctx, s.db, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &id, nil
+}
+
+// Update заменяет запись с идентификатором entry.ID.
+func (s *Storage) Update(ctx context.Context, entry *feed.Entry) error {
+	const op = "storage.sqlite.Update"
+
+	if entry.ID == nil {
+		return fmt.Errorf("%s: entry id is empty", op)
+	}
+
+	args, err := entryArgs(entry)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := update(ctx, s.db, args, *entry.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Bulk сохраняет записи в одной транзакции: записи без идентификатора
+// добавляет, записи с идентификатором заменяют запись с этим идентификатором.
+// Каждая запись сохраняется в своей точке сохранения, поэтому ошибка одной
+// записи не отменяет остальные: ошибки записей возвращаются в *storage.BulkError.
+// Идентификаторы новых записей заполняются в entries.
+func (s *Storage) Bulk(ctx context.Context, entries *[]feed.Entry) error {
+	const op = "storage.sqlite.Bulk"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	bulkErr := &storage.BulkError{Items: make(map[int]error)}
+	for i := range *entries {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT entry_item`); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := bulkItem(ctx, tx, &(*entries)[i]); err != nil {
+			bulkErr.Items[i] = err
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO entry_item`); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE entry_item`); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(bulkErr.Items) > 0 {
+		return fmt.Errorf("%s: %w", op, bulkErr)
+	}
+
+	return nil
+}
+
+// bulkItem добавляет запись e или заменяет запись с идентификатором e.ID.
+func bulkItem(ctx context.Context, db execer, e *feed.Entry) error {
+	args, err := entryArgs(e)
+	if err != nil {
+		return err
+	}
+	if e.ID != nil {
+		return update(ctx, db, args, *e.ID)
+	}
+	id, err := insert(ctx, db, args)
+	if err != nil {
+		return err
+	}
+	e.ID = &id
+	return nil
+}
+
+// execer общий интерфейс *sql.DB и *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// insert добавляет запись со значениями колонок args и возвращает её идентификатор.
+func insert(ctx context.Context, db execer, args []any) (int64, error) {
+	res, err := db.ExecContext(ctx, `INSERT INTO entry(
+	    language, url, title, summary, content, updated, published,
+	    author, number, resource_id, categories, tags, persons, regions,
+	    attachments, event_key, counterpart_url, counterpart_id
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, storage.ErrURLExists
+		}
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// update заменяет значения колонок записи с идентификатором id.
+func update(ctx context.Context, db execer, args []any, id int64) error {
+	res, err := db.ExecContext(ctx, `UPDATE entry SET
+	    language = ?, url = ?, title = ?, summary = ?, content = ?, updated = ?, published = ?,
+	    author = ?, number = ?, resource_id = ?, categories = ?, tags = ?, persons = ?, regions = ?,
+	    attachments = ?, event_key = ?, counterpart_url = ?, counterpart_id = ?
+	WHERE id = ?`, append(args, id)...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// entryArgs возвращает значения колонок записи в порядке INSERT и UPDATE запросов.
+func entryArgs(e *feed.Entry) ([]any, error) {
+	var jsonArgs []any
+	for _, v := range []any{e.Categories, e.Tags, e.Persons, e.Regions, e.Attachments} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		jsonArgs = append(jsonArgs, string(data))
+	}
+
+	var counterpartID sql.NullInt64
+	if e.CounterpartID != nil {
+		counterpartID = sql.NullInt64{Int64: *e.CounterpartID, Valid: true}
+	}
+
+	return []any{
+		e.Language, e.Url, e.Title, e.Summary, e.Content, nullTime(e.Updated), nullTime(e.Published),
+		e.Author, e.Number, e.ResourceID, jsonArgs[0], jsonArgs[1], jsonArgs[2], jsonArgs[3],
+		jsonArgs[4], e.EventKey, e.CounterpartUrl, counterpartID,
+	}, nil
+}
+
+// filterWhere возвращает условия запроса и их параметры для filter.
+func filterWhere(f feed.Filter) ([]string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	if f.Language != "" {
+		where = append(where, "e.language = ?")
+		args = append(args, f.Language)
+	}
+	if f.ResourceID != 0 {
+		where = append(where, "e.resource_id = ?")
+		args = append(args, f.ResourceID)
+	}
+	if f.Since != nil {
+		where = append(where, "e.published >= ?")
+		args = append(args, nullTime(f.Since))
+	}
+	if f.Until != nil {
+		where = append(where, "e.published < ?")
+		args = append(args, nullTime(f.Until))
+	}
+	return where, args
+}
+
+// nullTime возвращает время в UTC, чтобы значения в базе сравнивались как строки.
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// scanner общий интерфейс sql.Row и sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEntry читает запись, выбранную колонками entryColumns.
+func scanEntry(row scanner) (*feed.Entry, error) {
+	var (
+		e                                  feed.Entry
+		id                                 int64
+		updated, published                 sql.NullTime
+		categories, tags, persons, regions sql.NullString
+		attachments                        sql.NullString
+		counterpartID                      sql.NullInt64
+	)
+	err := row.Scan(
+		&id, &e.Language, &e.Url, &e.Title, &e.Summary, &e.Content, &updated, &published,
+		&e.Author, &e.Number, &e.ResourceID, &categories, &tags, &persons, &regions,
+		&attachments, &e.EventKey, &e.CounterpartUrl, &counterpartID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	e.ID = &id
+	if updated.Valid {
+		e.Updated = &updated.Time
+	}
+	if published.Valid {
+		e.Published = &published.Time
+	}
+	if counterpartID.Valid {
+		e.CounterpartID = &counterpartID.Int64
+	}
+
+	for _, f := range []struct {
+		src sql.NullString
+		dst any
+	}{
+		{categories, &e.Categories},
+		{tags, &e.Tags},
+		{persons, &e.Persons},
+		{regions, &e.Regions},
+		{attachments, &e.Attachments},
+	} {
+		if !f.src.Valid || f.src.String == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(f.src.String), f.dst); err != nil {
+			return nil, err
+		}
+	}
+
+	return &e, nil
+}
+
+// scanEntries читает все записи из rows и закрывает rows.
+func scanEntries(rows *sql.Rows) ([]feed.Entry, error) {
+	defer rows.Close()
+
+	var entries []feed.Entry
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
 }
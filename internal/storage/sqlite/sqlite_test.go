@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/storage"
+	"github.com/terratensor/kremlin-parser/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "feed.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := storagetest.Run(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBulkItemErrors(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(filepath.Join(t.TempDir(), "feed.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.Insert(ctx, &feed.Entry{Url: "https://example.test/a", Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := int64(1000)
+	entries := []feed.Entry{
+		{Url: "https://example.test/a", Title: "duplicate"},
+		{ID: &missing, Url: "https://example.test/missing", Title: "missing"},
+		{Url: "https://example.test/b", Title: "b"},
+	}
+	err = s.Bulk(ctx, &entries)
+
+	var bulkErr *storage.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("Bulk() error = %v, want *storage.BulkError", err)
+	}
+	if !errors.Is(bulkErr.Items[0], storage.ErrURLExists) {
+		t.Errorf("item 0: got %v, want %v", bulkErr.Items[0], storage.ErrURLExists)
+	}
+	if !errors.Is(bulkErr.Items[1], storage.ErrNotFound) {
+		t.Errorf("item 1: got %v, want %v", bulkErr.Items[1], storage.ErrNotFound)
+	}
+	if bulkErr.Items[2] != nil || entries[2].ID == nil {
+		t.Fatalf("item 2: got %v, id %v, want saved entry", bulkErr.Items[2], entries[2].ID)
+	}
+
+	e, err := s.FindByUrl(ctx, "https://example.test/b")
+	if err != nil || e == nil || *e.ID != *entries[2].ID {
+		t.Errorf("FindByUrl(b) = %v, %v, want entry %d", e, err, *entries[2].ID)
+	}
+	e, err = s.FindByUrl(ctx, "https://example.test/a")
+	if err != nil || e == nil || e.Title != "a" {
+		t.Errorf("FindByUrl(a) = %v, %v, want unchanged entry", e, err)
+	}
+}
@@ -1,8 +1,23 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrURLNotFound = errors.New("url not found")
 	ErrURLExists   = errors.New("url exists")
+	ErrNotFound    = errors.New("entry not found")
 )
+
+// BulkError возвращается из Bulk, если часть записей не сохранена.
+// Записи, которых нет в Items, сохранены, их идентификаторы заполнены.
+type BulkError struct {
+	// Items ошибки сохранения записей по их индексам в переданном слайсе
+	Items map[int]error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d entries not saved", len(e.Items))
+}
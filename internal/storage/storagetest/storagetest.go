@@ -0,0 +1,437 @@
+// Package storagetest проверяет, что хранилище записей соблюдает
+// контракт feed.StorageInterface. Проверки общие для всех хранилищ
+// и запускаются из тестов пакетов хранилищ.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terratensor/kremlin-parser/internal/entities/feed"
+	"github.com/terratensor/kremlin-parser/internal/storage"
+)
+
+// Run проверяет хранилище store и возвращает ошибку первой не пройденной проверки.
+//
+// Проверка добавляет записи с уникальными адресами и языком и удаляет их
+// по завершении, поэтому записи хранилища она не затрагивает. Хранилища,
+// которые только дописывают файлы (jsonl), сохраняют удаленные записи
+// в отдельном каталоге языка проверки.
+func Run(ctx context.Context, store feed.StorageInterface) error {
+	run := uuid.NewString()
+	s := &suite{
+		store:  store,
+		prefix: "https://example.test/" + run + "/",
+		lang:   "test-" + run[:8],
+		day:    time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC),
+	}
+	defer s.cleanup()
+
+	checks := []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{"insert", s.checkInsert},
+		{"find", s.checkFind},
+		{"update", s.checkUpdate},
+		{"bulk", s.checkBulk},
+		{"count", s.checkCount},
+		{"list", s.checkList},
+		{"delete", s.checkDelete},
+		{"replace", s.checkReplace},
+	}
+	for _, c := range checks {
+		if err := c.fn(ctx); err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+
+	return nil
+}
+
+type suite struct {
+	store  feed.StorageInterface
+	prefix string
+	lang   string
+	day    time.Time
+	// ids идентификаторы добавленных записей по адресам
+	ids map[string]int64
+}
+
+// entry возвращает тестовую запись с адресом prefix+name, опубликованную
+// через days суток после s.day.
+func (s *suite) entry(name string, resourceID int, days int) feed.Entry {
+	published := s.day.AddDate(0, 0, days)
+	return feed.Entry{
+		Language:   s.lang,
+		Title:      "title " + name,
+		Url:        s.prefix + name,
+		Updated:    &published,
+		Published:  &published,
+		Summary:    "summary " + name,
+		Content:    "<p>content " + name + "</p>",
+		Author:     "author " + name,
+		Number:     name + "-1",
+		ResourceID: resourceID,
+		Categories: []string{"category " + name},
+		Tags:       []string{"tag " + name, "tag"},
+		Persons:    []string{"person " + name},
+		Regions:    []string{"region " + name},
+		Attachments: []feed.Attachment{{
+			Url:      s.prefix + name + ".jpg",
+			MimeType: "image/jpeg",
+			Size:     1024,
+			Caption:  "caption " + name,
+			Kind:     feed.AttachmentPhoto,
+			Digest:   "digest " + name,
+			Path:     "attachments/" + name + ".jpg",
+		}},
+		EventKey:       s.prefix + "event",
+		CounterpartUrl: s.prefix + "en/" + name,
+	}
+}
+
+func (s *suite) checkInsert(ctx context.Context) error {
+	s.ids = make(map[string]int64)
+	for i, e := range []feed.Entry{s.entry("a", 1, 0), s.entry("b", 1, 1), s.entry("c", 2, 2)} {
+		id, err := s.store.Insert(ctx, &e)
+		if err != nil {
+			return err
+		}
+		if id == nil {
+			return fmt.Errorf("entry %d: no id returned", i)
+		}
+		s.ids[e.Url] = *id
+	}
+	if len(s.ids) != 3 {
+		return fmt.Errorf("inserted entries got %d distinct urls, want 3", len(s.ids))
+	}
+	return nil
+}
+
+func (s *suite) checkFind(ctx context.Context) error {
+	want := s.entry("a", 1, 0)
+	id := s.ids[want.Url]
+
+	e, err := s.store.FindByUrl(ctx, want.Url)
+	if err != nil {
+		return fmt.Errorf("FindByUrl: %w", err)
+	}
+	if err := compare(e, &want, id); err != nil {
+		return fmt.Errorf("FindByUrl: %w", err)
+	}
+
+	e, err = s.store.FindByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("FindByID: %w", err)
+	}
+	if err := compare(e, &want, id); err != nil {
+		return fmt.Errorf("FindByID: %w", err)
+	}
+
+	if e, err := s.store.FindByUrl(ctx, s.prefix+"missing"); err != nil || e != nil {
+		return fmt.Errorf("FindByUrl of missing entry = %v, %v, want nil, nil", e, err)
+	}
+
+	entries, err := s.store.FindByUrls(ctx, []string{s.prefix + "a", s.prefix + "missing", s.prefix + "c"})
+	if err != nil {
+		return fmt.Errorf("FindByUrls: %w", err)
+	}
+	if err := sameUrls(entries, s.prefix+"a", s.prefix+"c"); err != nil {
+		return fmt.Errorf("FindByUrls: %w", err)
+	}
+
+	entries, err = s.store.FindByEventKey(ctx, s.prefix+"event")
+	if err != nil {
+		return fmt.Errorf("FindByEventKey: %w", err)
+	}
+	if err := sameUrls(entries, s.prefix+"a", s.prefix+"b", s.prefix+"c"); err != nil {
+		return fmt.Errorf("FindByEventKey: %w", err)
+	}
+
+	return nil
+}
+
+func (s *suite) checkUpdate(ctx context.Context) error {
+	want := s.entry("b", 1, 1)
+	id := s.ids[want.Url]
+	want.ID = &id
+	want.Title = "updated title"
+	counterpartID := s.ids[s.prefix+"a"]
+	want.CounterpartID = &counterpartID
+
+	if err := s.store.Update(ctx, &want); err != nil {
+		return err
+	}
+
+	e, err := s.store.FindByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("FindByID: %w", err)
+	}
+	return compare(e, &want, id)
+}
+
+func (s *suite) checkBulk(ctx context.Context) error {
+	existing := s.entry("c", 2, 2)
+	existing.Summary = "bulk summary"
+	id := s.ids[existing.Url]
+	existing.ID = &id
+	entries := []feed.Entry{existing, s.entry("d", 2, 3)}
+
+	if err := s.store.Bulk(ctx, &entries); err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].ID == nil {
+			return fmt.Errorf("%s: no id after bulk", entries[i].Url)
+		}
+	}
+	if *entries[0].ID != s.ids[existing.Url] {
+		return fmt.Errorf("%s: id changed from %d to %d", existing.Url, s.ids[existing.Url], *entries[0].ID)
+	}
+	s.ids[entries[1].Url] = *entries[1].ID
+
+	for i := range entries {
+		e, err := s.store.FindByUrl(ctx, entries[i].Url)
+		if err != nil {
+			return fmt.Errorf("FindByUrl: %w", err)
+		}
+		if err := compare(e, &entries[i], *entries[i].ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *suite) checkCount(ctx context.Context) error {
+	since, until := s.day.AddDate(0, 0, 1), s.day.AddDate(0, 0, 3)
+
+	for _, c := range []struct {
+		filter feed.Filter
+		want   int64
+	}{
+		{feed.Filter{Language: s.lang}, 4},
+		{feed.Filter{Language: s.lang, ResourceID: 2}, 2},
+		{feed.Filter{Language: s.lang, Since: &since}, 3},
+		{feed.Filter{Language: s.lang, Since: &since, Until: &until}, 2},
+	} {
+		n, err := s.store.Count(ctx, c.filter)
+		if err != nil {
+			return err
+		}
+		if n != c.want {
+			return fmt.Errorf("filter %+v: got %d entries, want %d", c.filter, n, c.want)
+		}
+	}
+
+	return nil
+}
+
+func (s *suite) checkList(ctx context.Context) error {
+	var (
+		after int64
+		urls  []string
+		pages int
+	)
+	for {
+		page, err := s.store.List(ctx, feed.Filter{Language: s.lang}, after, 3)
+		if err != nil {
+			return err
+		}
+		pages++
+		for i := range page {
+			if *page[i].ID <= after {
+				return fmt.Errorf("page %d: id %d is not after cursor %d", pages, *page[i].ID, after)
+			}
+			after = *page[i].ID
+			urls = append(urls, page[i].Url)
+		}
+		if len(page) < 3 {
+			break
+		}
+		if pages > 3 {
+			return errors.New("pagination does not stop")
+		}
+	}
+	if pages != 2 {
+		return fmt.Errorf("got %d pages, want 2", pages)
+	}
+
+	entries := make([]feed.Entry, 0, len(urls))
+	for _, url := range urls {
+		entries = append(entries, feed.Entry{Url: url})
+	}
+	if err := sameUrls(entries, s.prefix+"a", s.prefix+"b", s.prefix+"c", s.prefix+"d"); err != nil {
+		return err
+	}
+
+	since := s.day.AddDate(0, 0, 2)
+	page, err := s.store.List(ctx, feed.Filter{Language: s.lang, ResourceID: 2, Since: &since}, 0, 10)
+	if err != nil {
+		return err
+	}
+	return sameUrls(page, s.prefix+"c", s.prefix+"d")
+}
+
+func (s *suite) checkDelete(ctx context.Context) error {
+	url := s.prefix + "a"
+	id := s.ids[url]
+
+	if err := s.store.Delete(ctx, id); err != nil {
+		return err
+	}
+	delete(s.ids, url)
+
+	if e, err := s.store.FindByID(ctx, id); err != nil || e != nil {
+		return fmt.Errorf("FindByID of deleted entry = %v, %v, want nil, nil", e, err)
+	}
+	if e, err := s.store.FindByUrl(ctx, url); err != nil || e != nil {
+		return fmt.Errorf("FindByUrl of deleted entry = %v, %v, want nil, nil", e, err)
+	}
+	n, err := s.store.Count(ctx, feed.Filter{Language: s.lang})
+	if err != nil {
+		return err
+	}
+	if n != 3 {
+		return fmt.Errorf("got %d entries after delete, want 3", n)
+	}
+
+	if err := s.store.Delete(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("second delete: got %v, want %v", err, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// checkReplace проверяет, что Bulk заменяет запись по идентификатору,
+// а не по адресу: запись с тем же идентификатором и новым адресом заменяет
+// прежнюю, и по старому адресу она больше не находится.
+func (s *suite) checkReplace(ctx context.Context) error {
+	oldURL := s.prefix + "c"
+	id := s.ids[oldURL]
+
+	want := s.entry("e", 2, 2)
+	want.ID = &id
+	entries := []feed.Entry{want}
+	if err := s.store.Bulk(ctx, &entries); err != nil {
+		return err
+	}
+	delete(s.ids, oldURL)
+	s.ids[want.Url] = id
+
+	if entries[0].ID == nil || *entries[0].ID != id {
+		return fmt.Errorf("%s: got id %v after replace, want %d", want.Url, entries[0].ID, id)
+	}
+	e, err := s.store.FindByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("FindByID: %w", err)
+	}
+	if err := compare(e, &want, id); err != nil {
+		return fmt.Errorf("FindByID: %w", err)
+	}
+	e, err = s.store.FindByUrl(ctx, want.Url)
+	if err != nil {
+		return fmt.Errorf("FindByUrl: %w", err)
+	}
+	if err := compare(e, &want, id); err != nil {
+		return fmt.Errorf("FindByUrl: %w", err)
+	}
+	if e, err := s.store.FindByUrl(ctx, oldURL); err != nil || e != nil {
+		return fmt.Errorf("FindByUrl of replaced address = %v, %v, want nil, nil", e, err)
+	}
+
+	n, err := s.store.Count(ctx, feed.Filter{Language: s.lang})
+	if err != nil {
+		return err
+	}
+	if n != 3 {
+		return fmt.Errorf("got %d entries after replace, want 3", n)
+	}
+
+	return nil
+}
+
+// cleanup удаляет оставшиеся записи проверки.
+func (s *suite) cleanup() {
+	ctx := context.Background()
+	for _, id := range s.ids {
+		_ = s.store.Delete(ctx, id)
+	}
+	if syncer, ok := s.store.(feed.Syncer); ok {
+		_ = syncer.Sync()
+	}
+}
+
+// compare сравнивает сохраненную запись got с want. Даты сравниваются
+// с точностью до секунды: некоторые хранилища хранят их в секундах unix.
+func compare(got, want *feed.Entry, id int64) error {
+	if got == nil {
+		return fmt.Errorf("%s: entry not found", want.Url)
+	}
+	if got.ID == nil || *got.ID != id {
+		return fmt.Errorf("%s: got id %v, want %d", want.Url, got.ID, id)
+	}
+
+	for _, f := range []struct {
+		name      string
+		got, want any
+	}{
+		{"url", got.Url, want.Url},
+		{"language", got.Language, want.Language},
+		{"title", got.Title, want.Title},
+		{"summary", got.Summary, want.Summary},
+		{"content", got.Content, want.Content},
+		{"author", got.Author, want.Author},
+		{"number", got.Number, want.Number},
+		{"resource_id", got.ResourceID, want.ResourceID},
+		{"categories", fmt.Sprint(got.Categories), fmt.Sprint(want.Categories)},
+		{"tags", fmt.Sprint(got.Tags), fmt.Sprint(want.Tags)},
+		{"persons", fmt.Sprint(got.Persons), fmt.Sprint(want.Persons)},
+		{"regions", fmt.Sprint(got.Regions), fmt.Sprint(want.Regions)},
+		{"attachments", fmt.Sprintf("%+v", got.Attachments), fmt.Sprintf("%+v", want.Attachments)},
+		{"event_key", got.EventKey, want.EventKey},
+		{"counterpart_url", got.CounterpartUrl, want.CounterpartUrl},
+		{"counterpart_id", idString(got.CounterpartID), idString(want.CounterpartID)},
+	} {
+		if f.got != f.want {
+			return fmt.Errorf("%s: got %s %v, want %v", want.Url, f.name, f.got, f.want)
+		}
+	}
+
+	if got.Published == nil || got.Published.Unix() != want.Published.Unix() {
+		return fmt.Errorf("%s: got published %v, want %v", want.Url, got.Published, want.Published)
+	}
+
+	return nil
+}
+
+// idString возвращает идентификатор в виде строки или "nil".
+func idString(id *int64) string {
+	if id == nil {
+		return "nil"
+	}
+	return fmt.Sprint(*id)
+}
+
+// sameUrls проверяет, что entries — это записи с адресами urls в любом порядке.
+func sameUrls(entries []feed.Entry, urls ...string) error {
+	got := make(map[string]int, len(entries))
+	for _, e := range entries {
+		got[e.Url]++
+	}
+	for _, url := range urls {
+		if got[url] != 1 {
+			return fmt.Errorf("got %d entries with url %s, want 1", got[url], url)
+		}
+		delete(got, url)
+	}
+	for url := range got {
+		return fmt.Errorf("unexpected entry %s", url)
+	}
+	return nil
+}
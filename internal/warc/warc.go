@@ -0,0 +1,196 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Version версия формата записей.
+const Version = "WARC/1.1"
+
+// software значение поля software записи warcinfo.
+const software = "kremlin-parser"
+
+// Writer записывает пары запрос/ответ в файлы WARC 1.1.
+//
+// Файлы создаются в каталоге dir с именами prefix-20060102150405-00001.warc.gz,
+// каждая запись сжимается отдельным gzip потоком, как рекомендует стандарт,
+// поэтому файл можно читать с любой записи. Когда размер файла достигает
+// maxSize, создается следующий. Безопасен для одновременного использования.
+type Writer struct {
+	dir     string
+	prefix  string
+	maxSize int64
+
+	mu     sync.Mutex
+	f      *os.File
+	name   string
+	size   int64
+	serial int
+}
+
+// NewWriter создает Writer, файлы создаются при первой записи.
+// maxSize 0 отключает ротацию файлов.
+func NewWriter(dir, prefix string, maxSize int64) (*Writer, error) {
+	const op = "warc.NewWriter"
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Writer{dir: dir, prefix: prefix, maxSize: maxSize}, nil
+}
+
+// WriteExchange записывает запись request запроса req и связанную с ней
+// запись response ответа resp с телом body. Записи пары всегда попадают в один файл.
+//
+// Тело ответа body и заголовки resp записываются в том виде, в котором они
+// переданы по сети: сжатое сервером тело записывается сжатым вместе с заголовками
+// Content-Encoding и Content-Length, WARC-Payload-Digest считается по нему же.
+func (w *Writer) WriteExchange(req *http.Request, resp *http.Response, body []byte) error {
+	const op = "warc.Writer.WriteExchange"
+
+	date := time.Now().UTC()
+	target := req.URL.String()
+
+	var reqBlock bytes.Buffer
+	if err := req.Write(&reqBlock); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var respBlock bytes.Buffer
+	fmt.Fprintf(&respBlock, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	if err := resp.Header.Write(&respBlock); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	respBlock.WriteString("\r\n")
+	respBlock.Write(body)
+
+	respID := recordID()
+	response := []field{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", respID},
+		{"WARC-Date", date.Format(time.RFC3339)},
+		{"WARC-Target-URI", target},
+		{"WARC-Payload-Digest", digest(body)},
+		{"Content-Type", "application/http;msgtype=response"},
+	}
+	request := []field{
+		{"WARC-Type", "request"},
+		{"WARC-Record-ID", recordID()},
+		{"WARC-Date", date.Format(time.RFC3339)},
+		{"WARC-Target-URI", target},
+		{"WARC-Concurrent-To", respID},
+		{"Content-Type", "application/http;msgtype=request"},
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.rotate(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := w.writeRecord(request, reqBlock.Bytes()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := w.writeRecord(response, respBlock.Bytes()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Close закрывает текущий файл.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// rotate открывает новый файл, если файла еще нет или текущий достиг maxSize,
+// и записывает в его начало запись warcinfo.
+func (w *Writer) rotate() error {
+	if w.f != nil && (w.maxSize <= 0 || w.size < w.maxSize) {
+		return nil
+	}
+	if w.f != nil {
+		if err := w.f.Close(); err != nil {
+			return err
+		}
+		w.f = nil
+	}
+
+	w.serial++
+	w.name = fmt.Sprintf("%s-%s-%05d.warc.gz", w.prefix, time.Now().UTC().Format("20060102150405"), w.serial)
+	f, err := os.OpenFile(filepath.Join(w.dir, w.name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	w.size = 0
+
+	info := fmt.Sprintf("software: %s\r\nformat: WARC File Format 1.1\r\nconformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n", software)
+	return w.writeRecord([]field{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", recordID()},
+		{"WARC-Date", time.Now().UTC().Format(time.RFC3339)},
+		{"WARC-Filename", w.name},
+		{"Content-Type", "application/warc-fields"},
+	}, []byte(info))
+}
+
+// field поле заголовка записи WARC.
+type field struct {
+	name, value string
+}
+
+// writeRecord записывает запись с заголовками fields и блоком block
+// отдельным gzip потоком. Длина и хеш блока добавляются автоматически.
+func (w *Writer) writeRecord(fields []field, block []byte) error {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+
+	fmt.Fprintf(gz, "%s\r\n", Version)
+	for _, f := range fields {
+		fmt.Fprintf(gz, "%s: %s\r\n", f.name, f.value)
+	}
+	fmt.Fprintf(gz, "WARC-Block-Digest: %s\r\n", digest(block))
+	fmt.Fprintf(gz, "Content-Length: %s\r\n\r\n", strconv.Itoa(len(block)))
+	gz.Write(block)
+	gz.Write([]byte("\r\n\r\n"))
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	n, err := w.f.Write(b.Bytes())
+	w.size += int64(n)
+	return err
+}
+
+// recordID возвращает новый идентификатор записи.
+func recordID() string {
+	return "<urn:uuid:" + uuid.NewString() + ">"
+}
+
+// digest возвращает sha1 хеш данных в принятом в WARC виде sha1:BASE32.
+func digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}